@@ -0,0 +1,150 @@
+package gopunch
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+)
+
+// Authenticator
+//
+//	adds credentials to every request sent by the client
+type Authenticator interface {
+	Authenticate(req *http.Request) error
+}
+
+// Refresher
+//
+//	implemented by authenticators that can renew their credentials
+//	when the server answers 401, Refresh is called once and the request is replayed
+type Refresher interface {
+	Refresh(ctx context.Context, resp *http.Response) error
+}
+
+// AuthenticatorFunc
+//
+//	lets a plain function be used as Authenticator
+type AuthenticatorFunc func(req *http.Request) error
+
+func (f AuthenticatorFunc) Authenticate(req *http.Request) error {
+	return f(req)
+}
+
+// BasicAuth
+//
+//	takes username and password
+//	returns Authenticator setting HTTP Basic credentials
+func BasicAuth(username, password string) Authenticator {
+	return AuthenticatorFunc(func(req *http.Request) error {
+		req.SetBasicAuth(username, password)
+		return nil
+	})
+}
+
+// BearerToken
+//
+//	takes a static token
+//	returns Authenticator setting "Authorization: Bearer <token>"
+func BearerToken(token string) Authenticator {
+	return AuthenticatorFunc(func(req *http.Request) error {
+		req.Header.Set("Authorization", "Bearer "+token)
+		return nil
+	})
+}
+
+// APIKeyHeader
+//
+//	takes header name and key
+//	returns Authenticator sending the key in that header
+func APIKeyHeader(header, key string) Authenticator {
+	return AuthenticatorFunc(func(req *http.Request) error {
+		req.Header.Set(header, key)
+		return nil
+	})
+}
+
+// APIKeyQuery
+//
+//	takes query parameter name and key
+//	returns Authenticator sending the key as a query parameter
+func APIKeyQuery(param, key string) Authenticator {
+	return AuthenticatorFunc(func(req *http.Request) error {
+		query := req.URL.Query()
+		query.Set(param, key)
+		req.URL.RawQuery = query.Encode()
+		return nil
+	})
+}
+
+// roundTrip authenticates and sends a single attempt, replaying it once after a 401
+// when the authenticator can refresh its credentials
+func (c *Client) roundTrip(req *http.Request) (*http.Response, error) {
+	authenticator := c.authenticator
+	if authenticator == nil {
+		return c.exchange(req)
+	}
+
+	if err := authenticator.Authenticate(req); err != nil {
+		return nil, err
+	}
+
+	resp, err := c.exchange(req)
+	if err != nil || resp.StatusCode != http.StatusUnauthorized {
+		return resp, err
+	}
+
+	refresher, ok := authenticator.(Refresher)
+	if !ok || !canReplay(req) {
+		return resp, nil
+	}
+
+	if err := refresher.Refresh(req.Context(), resp); err != nil {
+		discard(resp)
+		return nil, err
+	}
+
+	replay, err := replayRequest(req)
+	if err != nil {
+		discard(resp)
+		return nil, err
+	}
+
+	discard(resp)
+
+	if err := authenticator.Authenticate(replay); err != nil {
+		return nil, err
+	}
+
+	return c.exchange(replay)
+}
+
+// requestBody returns the body of req, buffering it so it can still be sent when it cannot be read twice
+func requestBody(req *http.Request) ([]byte, error) {
+	if req.Body == nil || req.Body == http.NoBody {
+		return nil, nil
+	}
+
+	if req.GetBody != nil {
+		body, err := req.GetBody()
+		if err != nil {
+			return nil, err
+		}
+		defer body.Close()
+
+		return io.ReadAll(body)
+	}
+
+	data, err := io.ReadAll(req.Body)
+	req.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+
+	req.Body = io.NopCloser(bytes.NewReader(data))
+	req.GetBody = func() (io.ReadCloser, error) {
+		return io.NopCloser(bytes.NewReader(data)), nil
+	}
+
+	return data, nil
+}
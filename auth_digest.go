@@ -0,0 +1,292 @@
+package gopunch
+
+import (
+	"context"
+	"crypto/md5"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash"
+	"net/http"
+	"strings"
+	"sync"
+)
+
+var ErrNoDigestChallenge = errors.New("no supported digest challenge in WWW-Authenticate")
+
+type digestChallenge struct {
+	realm     string
+	nonce     string
+	opaque    string
+	algorithm string
+	qop       string
+	userhash  bool
+}
+
+// DigestAuth
+//
+//	RFC 7616 Digest authentication with MD5 and SHA-256, qop=auth
+//	the first request is sent without credentials, the 401 challenge is answered
+//	by replaying it, later requests reuse the nonce with an increasing nonce count
+type DigestAuth struct {
+	username   string
+	password   string
+	mu         sync.Mutex
+	challenge  *digestChallenge
+	nonceCount uint32
+	cnonce     func() string
+}
+
+// NewDigestAuth
+//
+//	takes username and password
+//	returns *DigestAuth
+func NewDigestAuth(username, password string) *DigestAuth {
+	return &DigestAuth{
+		username: username,
+		password: password,
+		cnonce:   randomCnonce,
+	}
+}
+
+func randomCnonce() string {
+	var b [16]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		panic(err)
+	}
+
+	return hex.EncodeToString(b[:])
+}
+
+// Authenticate
+//
+//	answers the last challenge, does nothing until a challenge was received
+func (d *DigestAuth) Authenticate(req *http.Request) error {
+	d.mu.Lock()
+	challenge := d.challenge
+	d.nonceCount++
+	nonceCount := d.nonceCount
+	d.mu.Unlock()
+
+	if challenge == nil {
+		return nil
+	}
+
+	req.Header.Set("Authorization", d.authorization(challenge, req.Method, req.URL.RequestURI(), nonceCount, d.cnonce()))
+
+	return nil
+}
+
+// Refresh
+//
+//	reads the challenge of a 401 response, SHA-256 is preferred over MD5
+func (d *DigestAuth) Refresh(ctx context.Context, resp *http.Response) error {
+	var best *digestChallenge
+	for _, challenge := range parseChallenges(resp.Header.Values("WWW-Authenticate")) {
+		if !strings.EqualFold(challenge.scheme, "digest") {
+			continue
+		}
+
+		candidate := &digestChallenge{
+			realm:     challenge.params["realm"],
+			nonce:     challenge.params["nonce"],
+			opaque:    challenge.params["opaque"],
+			algorithm: challenge.params["algorithm"],
+			userhash:  strings.EqualFold(challenge.params["userhash"], "true"),
+		}
+
+		if candidate.algorithm == "" {
+			candidate.algorithm = "MD5"
+		}
+
+		if digestHash(candidate.algorithm) == nil || candidate.nonce == "" {
+			continue
+		}
+
+		if qop, ok := challenge.params["qop"]; ok {
+			if !tokenListContains(qop, "auth") {
+				continue
+			}
+			candidate.qop = "auth"
+		}
+
+		if best == nil || digestStrength(candidate.algorithm) > digestStrength(best.algorithm) {
+			best = candidate
+		}
+	}
+
+	if best == nil {
+		return ErrNoDigestChallenge
+	}
+
+	d.mu.Lock()
+	d.challenge = best
+	d.nonceCount = 0
+	d.mu.Unlock()
+
+	return nil
+}
+
+func (d *DigestAuth) authorization(challenge *digestChallenge, method, uri string, nonceCount uint32, cnonce string) string {
+	h := func(parts ...string) string {
+		hasher := digestHash(challenge.algorithm)()
+		hasher.Write([]byte(strings.Join(parts, ":")))
+		return hex.EncodeToString(hasher.Sum(nil))
+	}
+
+	nc := fmt.Sprintf("%08x", nonceCount)
+	ha1 := h(d.username, challenge.realm, d.password)
+	if strings.HasSuffix(strings.ToLower(challenge.algorithm), "-sess") {
+		ha1 = h(ha1, challenge.nonce, cnonce)
+	}
+
+	ha2 := h(method, uri)
+
+	var response string
+	if challenge.qop == "" {
+		response = h(ha1, challenge.nonce, ha2)
+	} else {
+		response = h(ha1, challenge.nonce, nc, cnonce, challenge.qop, ha2)
+	}
+
+	username := d.username
+	if challenge.userhash {
+		username = h(d.username, challenge.realm)
+	}
+
+	fields := []string{
+		fmt.Sprintf("username=%q", username),
+		fmt.Sprintf("realm=%q", challenge.realm),
+		fmt.Sprintf("nonce=%q", challenge.nonce),
+		fmt.Sprintf("uri=%q", uri),
+		"algorithm=" + challenge.algorithm,
+		fmt.Sprintf("response=%q", response),
+	}
+
+	if challenge.opaque != "" {
+		fields = append(fields, fmt.Sprintf("opaque=%q", challenge.opaque))
+	}
+
+	if challenge.qop != "" {
+		fields = append(fields, "qop="+challenge.qop, "nc="+nc, fmt.Sprintf("cnonce=%q", cnonce))
+	}
+
+	if challenge.userhash {
+		fields = append(fields, "userhash=true")
+	}
+
+	return "Digest " + strings.Join(fields, ", ")
+}
+
+func digestHash(algorithm string) func() hash.Hash {
+	switch strings.ToUpper(strings.TrimSuffix(strings.ToLower(algorithm), "-sess")) {
+	case "MD5":
+		return md5.New
+	case "SHA-256":
+		return sha256.New
+	}
+
+	return nil
+}
+
+func digestStrength(algorithm string) int {
+	if strings.HasPrefix(strings.ToUpper(algorithm), "SHA-256") {
+		return 2
+	}
+
+	return 1
+}
+
+func tokenListContains(list, token string) bool {
+	for _, item := range strings.Split(list, ",") {
+		if strings.EqualFold(strings.TrimSpace(item), token) {
+			return true
+		}
+	}
+
+	return false
+}
+
+type authChallenge struct {
+	scheme string
+	params map[string]string
+}
+
+// parseChallenges parses WWW-Authenticate values, which may hold several challenges each
+func parseChallenges(values []string) []authChallenge {
+	var challenges []authChallenge
+	for _, value := range values {
+		p := &headerParser{value: value}
+		current := -1
+		for {
+			p.skip(" \t,")
+			if p.done() {
+				break
+			}
+
+			token := p.token()
+			p.skip(" \t")
+
+			// a token not followed by "=" starts a new challenge
+			if p.done() || p.value[p.pos] != '=' {
+				challenges = append(challenges, authChallenge{scheme: token, params: map[string]string{}})
+				current = len(challenges) - 1
+				continue
+			}
+
+			p.pos++
+			p.skip(" \t")
+			paramValue := p.quotedOrToken()
+			if current >= 0 {
+				challenges[current].params[strings.ToLower(token)] = paramValue
+			}
+		}
+	}
+
+	return challenges
+}
+
+type headerParser struct {
+	value string
+	pos   int
+}
+
+func (p *headerParser) done() bool {
+	return p.pos >= len(p.value)
+}
+
+func (p *headerParser) skip(chars string) {
+	for !p.done() && strings.IndexByte(chars, p.value[p.pos]) >= 0 {
+		p.pos++
+	}
+}
+
+func (p *headerParser) token() string {
+	start := p.pos
+	for !p.done() && strings.IndexByte(" \t,=", p.value[p.pos]) < 0 {
+		p.pos++
+	}
+
+	return p.value[start:p.pos]
+}
+
+func (p *headerParser) quotedOrToken() string {
+	if p.done() || p.value[p.pos] != '"' {
+		return p.token()
+	}
+
+	p.pos++
+	var b strings.Builder
+	for !p.done() && p.value[p.pos] != '"' {
+		if p.value[p.pos] == '\\' && p.pos+1 < len(p.value) {
+			p.pos++
+		}
+		b.WriteByte(p.value[p.pos])
+		p.pos++
+	}
+	p.pos++
+
+	return b.String()
+}
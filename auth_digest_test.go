@@ -0,0 +1,136 @@
+package gopunch
+
+import (
+	"context"
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+var DigestAuthorizationTestCases = []struct {
+	Title     string
+	Algorithm string
+	Expected  string
+}{
+	{
+		Title:     "Given RFC 7616 MD5 example; response should match the RFC",
+		Algorithm: "MD5",
+		Expected:  "8ca523f5e9506fed4657c9700eebdbec",
+	},
+	{
+		Title:     "Given RFC 7616 SHA-256 example; response should match the RFC",
+		Algorithm: "SHA-256",
+		Expected:  "753927fa0e85d155564e2e272a28d1802ca10daf4496794697cf8db5856cb6c1",
+	},
+}
+
+func Test_DigestAuth_Authorization(t *testing.T) {
+	for _, testCase := range DigestAuthorizationTestCases {
+		t.Log(testCase.Title)
+		auth := NewDigestAuth("Mufasa", "Circle of Life")
+		challenge := &digestChallenge{
+			realm:     "http-auth@example.org",
+			nonce:     "7ypf/xlj9XXwfDPEoM4URrv/xwf94BcCAzFZH4GiTo0v",
+			opaque:    "FQhe/qaU925kfnzjCev0ciny7QMkPqMAFRtzCUYo5tdS",
+			algorithm: testCase.Algorithm,
+			qop:       "auth",
+		}
+
+		header := auth.authorization(challenge, http.MethodGet, "/dir/index.html", 1, "f2/wE4q74E6zIJEtWaHKaf5wv/H5QzzpXusqGemxURZJ")
+		params := parseChallenges([]string{header})[0].params
+		if params["response"] != testCase.Expected || params["nc"] != "00000001" || params["opaque"] != challenge.opaque {
+			t.Fatal(header)
+		}
+	}
+}
+
+func Test_ParseChallenges(t *testing.T) {
+	t.Log("Given several challenges across header lines; each should be parsed with its own params")
+	challenges := parseChallenges([]string{
+		`Basic realm="basic", Digest realm="x", qop="auth, auth-int", algorithm=SHA-256, nonce="n\"1"`,
+		`Digest realm="x", algorithm=MD5, nonce="n2"`,
+	})
+
+	if len(challenges) != 3 {
+		t.Fatal(challenges)
+	}
+
+	if challenges[0].scheme != "Basic" || challenges[0].params["realm"] != "basic" {
+		t.Fatal(challenges[0])
+	}
+
+	if challenges[1].params["qop"] != "auth, auth-int" || challenges[1].params["nonce"] != `n"1` || challenges[1].params["algorithm"] != "SHA-256" {
+		t.Fatal(challenges[1])
+	}
+
+	if challenges[2].params["nonce"] != "n2" || challenges[2].params["algorithm"] != "MD5" {
+		t.Fatal(challenges[2])
+	}
+}
+
+func Test_DigestAuth_Server(t *testing.T) {
+	t.Log("Given digest protected server; client should answer the challenge, replay the body and reuse the nonce")
+	const nonce = "server-nonce"
+	var challenges int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		header := r.Header.Get("Authorization")
+		if header == "" {
+			atomic.AddInt32(&challenges, 1)
+			w.Header().Add("WWW-Authenticate", `Digest realm="test", qop="auth", algorithm=MD5, nonce="`+nonce+`"`)
+			w.Header().Add("WWW-Authenticate", `Digest realm="test", qop="auth", algorithm=SHA-256, nonce="`+nonce+`", opaque="op"`)
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+
+		params := parseChallenges([]string{header})[0].params
+		var newHash func() hash.Hash = md5.New
+		if params["algorithm"] == "SHA-256" {
+			newHash = sha256.New
+		}
+
+		h := func(parts ...string) string {
+			hasher := newHash()
+			hasher.Write([]byte(strings.Join(parts, ":")))
+			return hex.EncodeToString(hasher.Sum(nil))
+		}
+
+		expected := h(h("user", "test", "pass"), nonce, params["nc"], params["cnonce"], "auth", h(r.Method, params["uri"]))
+		if params["algorithm"] != "SHA-256" || params["opaque"] != "op" || params["response"] != expected {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+
+		body, _ := io.ReadAll(r.Body)
+		fmt.Fprintf(w, "%s %s", params["nc"], body)
+	}))
+	defer server.Close()
+
+	client := New(server.URL)
+	client.SetAuthenticator(NewDigestAuth("user", "pass"))
+
+	expected := []string{"00000001 payload", "00000002 payload"}
+	for _, want := range expected {
+		resp := client.Post(context.Background(), "/secure", []byte("payload"))
+		if resp.Err() != nil {
+			t.Fatal(resp.Err())
+		}
+
+		body, _ := io.ReadAll(resp.HttpResponse().Body)
+		resp.Close()
+		if resp.HttpResponse().StatusCode != http.StatusOK || string(body) != want {
+			t.Fatal(resp.HttpResponse().Status, string(body))
+		}
+	}
+
+	if atomic.LoadInt32(&challenges) != 1 {
+		t.Fatal(challenges)
+	}
+}
@@ -0,0 +1,219 @@
+package gopunch
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"hash"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// Default headers carrying the HMAC signature and its timestamp
+const (
+	DefaultSignatureHeader = "X-Signature"
+	DefaultTimestampHeader = "X-Timestamp"
+)
+
+var ErrMissingSignature = errors.New("missing signature or timestamp")
+var ErrInvalidSignature = errors.New("invalid signature")
+var ErrTimestampSkew = errors.New("timestamp outside of the allowed clock skew")
+var ErrBodyTooLarge = errors.New("webhook body exceeds the allowed size")
+
+// DefaultWebhookMaxBodySize
+//
+//	largest webhook body WebhookVerifier reads unless SetMaxBodySize says otherwise
+const DefaultWebhookMaxBodySize = 1 << 20
+
+// HMACCanonicalizer
+//
+//	builds the message that gets signed from the request, its unix timestamp and its body
+type HMACCanonicalizer func(req *http.Request, timestamp string, body []byte) []byte
+
+// DefaultHMACCanonicalizer
+//
+//	signs "timestamp\nMETHOD\n/path?query\nbody"
+func DefaultHMACCanonicalizer(req *http.Request, timestamp string, body []byte) []byte {
+	message := []byte(timestamp + "\n" + req.Method + "\n" + req.URL.RequestURI() + "\n")
+	return append(message, body...)
+}
+
+// HMACSigner
+//
+//	signs outgoing requests with a shared secret, usable as Authenticator
+//	the same signer verifies incoming webhooks, see WebhookVerifier
+type HMACSigner struct {
+	key             []byte
+	hash            func() hash.Hash
+	encode          func([]byte) string
+	prefix          string
+	signatureHeader string
+	timestampHeader string
+	canonicalize    HMACCanonicalizer
+}
+
+// NewHMACSigner
+//
+//	takes the shared secret
+//	returns *HMACSigner using HMAC-SHA256, hex signatures and DefaultHMACCanonicalizer
+func NewHMACSigner(key []byte) *HMACSigner {
+	return &HMACSigner{
+		key:             key,
+		hash:            sha256.New,
+		encode:          hex.EncodeToString,
+		signatureHeader: DefaultSignatureHeader,
+		timestampHeader: DefaultTimestampHeader,
+		canonicalize:    DefaultHMACCanonicalizer,
+	}
+}
+
+// SetHash
+//
+//	takes the hash function, such as sha512.New
+func (s *HMACSigner) SetHash(hash func() hash.Hash) {
+	s.hash = hash
+}
+
+// SetEncoding
+//
+//	takes how the signature is encoded, such as base64.StdEncoding.EncodeToString
+func (s *HMACSigner) SetEncoding(encode func([]byte) string) {
+	s.encode = encode
+}
+
+// SetSignaturePrefix
+//
+//	takes a prefix put in front of the encoded signature, such as "sha256="
+func (s *HMACSigner) SetSignaturePrefix(prefix string) {
+	s.prefix = prefix
+}
+
+// SetHeaders
+//
+//	takes the headers carrying the signature and the timestamp
+func (s *HMACSigner) SetHeaders(signatureHeader, timestampHeader string) {
+	s.signatureHeader = signatureHeader
+	s.timestampHeader = timestampHeader
+}
+
+// SetCanonicalizer
+//
+//	takes the function building the signed message
+func (s *HMACSigner) SetCanonicalizer(canonicalize HMACCanonicalizer) {
+	s.canonicalize = canonicalize
+}
+
+// Authenticate
+//
+//	sets the timestamp and signature headers
+func (s *HMACSigner) Authenticate(req *http.Request) error {
+	body, err := requestBody(req)
+	if err != nil {
+		return err
+	}
+
+	timestamp := strconv.FormatInt(nowFunc().Unix(), 10)
+	req.Header.Set(s.timestampHeader, timestamp)
+	req.Header.Set(s.signatureHeader, s.Signature(req, timestamp, body))
+
+	return nil
+}
+
+// Signature
+//
+//	returns the encoded signature, prefix included, of a request sent at timestamp
+func (s *HMACSigner) Signature(req *http.Request, timestamp string, body []byte) string {
+	mac := hmac.New(s.hash, s.key)
+	mac.Write(s.canonicalize(req, timestamp, body))
+
+	return s.prefix + s.encode(mac.Sum(nil))
+}
+
+// WebhookVerifier
+//
+//	checks the signature and timestamp of incoming webhook requests
+type WebhookVerifier struct {
+	signer      *HMACSigner
+	tolerance   time.Duration
+	maxBodySize int64
+}
+
+// NewWebhookVerifier
+//
+//	takes the signer configured like the sender's and the max allowed clock skew
+//	returns *WebhookVerifier reading bodies up to DefaultWebhookMaxBodySize
+func NewWebhookVerifier(signer *HMACSigner, tolerance time.Duration) *WebhookVerifier {
+	return &WebhookVerifier{signer: signer, tolerance: tolerance, maxBodySize: DefaultWebhookMaxBodySize}
+}
+
+// SetMaxBodySize
+//
+//	sets the largest body read to check the signature, larger requests fail with ErrBodyTooLarge
+func (v *WebhookVerifier) SetMaxBodySize(size int64) {
+	v.maxBodySize = size
+}
+
+// Verify
+//
+//	takes an incoming request, its body stays readable afterwards
+//	returns ErrMissingSignature, ErrTimestampSkew, ErrBodyTooLarge or ErrInvalidSignature when the request must be rejected
+func (v *WebhookVerifier) Verify(r *http.Request) error {
+	signature := r.Header.Get(v.signer.signatureHeader)
+	timestamp := r.Header.Get(v.signer.timestampHeader)
+	if signature == "" || timestamp == "" {
+		return ErrMissingSignature
+	}
+
+	seconds, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return ErrMissingSignature
+	}
+
+	skew := nowFunc().Sub(time.Unix(seconds, 0))
+	if skew > v.tolerance || skew < -v.tolerance {
+		return ErrTimestampSkew
+	}
+
+	if r.Body != nil {
+		r.Body = http.MaxBytesReader(nil, r.Body, v.maxBodySize)
+	}
+
+	body, err := requestBody(r)
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) {
+		return ErrBodyTooLarge
+	}
+
+	if err != nil {
+		return err
+	}
+
+	expected := v.signer.Signature(r, timestamp, body)
+	if !hmac.Equal([]byte(expected), []byte(signature)) {
+		return ErrInvalidSignature
+	}
+
+	return nil
+}
+
+// Middleware
+//
+//	takes the webhook handler
+//	returns http.Handler answering 413 to bodies over the max size and 401 to other requests failing Verify
+func (v *WebhookVerifier) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := v.Verify(r); err != nil {
+			status := http.StatusUnauthorized
+			if err == ErrBodyTooLarge {
+				status = http.StatusRequestEntityTooLarge
+			}
+
+			http.Error(w, err.Error(), status)
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
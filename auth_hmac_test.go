@@ -0,0 +1,136 @@
+package gopunch
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha512"
+	"encoding/base64"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func Test_HMACSigner_Webhook(t *testing.T) {
+	t.Log("Given signed client and verifying server; body should reach the handler and signature should pass")
+	signer := NewHMACSigner([]byte("shared-secret"))
+	verifier := NewWebhookVerifier(signer, 5*time.Minute)
+
+	server := httptest.NewServer(verifier.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		w.Write(body)
+	})))
+	defer server.Close()
+
+	client := New(server.URL)
+	client.SetAuthenticator(signer)
+
+	resp := client.Post(context.Background(), "/hooks/order", []byte(`{"id":1}`), WithQueries(map[string]string{"v": "2"}))
+	if resp.Err() != nil {
+		t.Fatal(resp.Err())
+	}
+
+	body, _ := io.ReadAll(resp.HttpResponse().Body)
+	resp.Close()
+	if resp.HttpResponse().StatusCode != http.StatusOK || string(body) != `{"id":1}` {
+		t.Fatal(resp.HttpResponse().Status, string(body))
+	}
+
+	t.Log("Given other secret; server should reject the request")
+	client.SetAuthenticator(NewHMACSigner([]byte("wrong")))
+	resp = client.Post(context.Background(), "/hooks/order", []byte(`{"id":1}`))
+	if resp.Err() != nil {
+		t.Fatal(resp.Err())
+	}
+	resp.Close()
+
+	if resp.HttpResponse().StatusCode != http.StatusUnauthorized {
+		t.Fatal(resp.HttpResponse().Status)
+	}
+}
+
+var WebhookVerifyTestCases = []struct {
+	Title    string
+	Age      time.Duration
+	Tamper   bool
+	Unsigned bool
+	Expected error
+}{
+	{
+		Title:    "Given fresh signed request; verify should pass",
+		Age:      time.Minute,
+		Expected: nil,
+	},
+	{
+		Title:    "Given request older than the tolerance; verify should report clock skew",
+		Age:      10 * time.Minute,
+		Expected: ErrTimestampSkew,
+	},
+	{
+		Title:    "Given request from the future beyond the tolerance; verify should report clock skew",
+		Age:      -10 * time.Minute,
+		Expected: ErrTimestampSkew,
+	},
+	{
+		Title:    "Given tampered body; verify should report invalid signature",
+		Tamper:   true,
+		Expected: ErrInvalidSignature,
+	},
+	{
+		Title:    "Given request without signature; verify should report missing signature",
+		Unsigned: true,
+		Expected: ErrMissingSignature,
+	},
+}
+
+func Test_WebhookVerifier_Verify(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	withFrozenClock(t, now)
+
+	signer := NewHMACSigner([]byte("secret"))
+	signer.SetHash(sha512.New)
+	signer.SetEncoding(base64.StdEncoding.EncodeToString)
+	signer.SetSignaturePrefix("sha512=")
+	signer.SetHeaders("X-Hub-Signature", "X-Hub-Timestamp")
+	signer.SetCanonicalizer(func(req *http.Request, timestamp string, body []byte) []byte {
+		return append([]byte(timestamp+"."), body...)
+	})
+	verifier := NewWebhookVerifier(signer, 5*time.Minute)
+
+	for _, testCase := range WebhookVerifyTestCases {
+		t.Log(testCase.Title)
+		body := []byte("event")
+		req := httptest.NewRequest(http.MethodPost, "/hook", bytes.NewReader(body))
+
+		if !testCase.Unsigned {
+			timestamp := strconv.FormatInt(now.Add(-testCase.Age).Unix(), 10)
+			req.Header.Set("X-Hub-Timestamp", timestamp)
+			req.Header.Set("X-Hub-Signature", signer.Signature(req, timestamp, body))
+		}
+
+		if testCase.Tamper {
+			req.Body = io.NopCloser(bytes.NewReader([]byte("evil")))
+		}
+
+		if err := verifier.Verify(req); !errors.Is(err, testCase.Expected) {
+			t.Fatal(err)
+		}
+	}
+
+	t.Log("Given signed body over the max size; it should be rejected with 413 before being buffered")
+	verifier.SetMaxBodySize(3)
+	body := []byte("event")
+	timestamp := strconv.FormatInt(now.Unix(), 10)
+	req := httptest.NewRequest(http.MethodPost, "/hook", bytes.NewReader(body))
+	req.Header.Set("X-Hub-Timestamp", timestamp)
+	req.Header.Set("X-Hub-Signature", signer.Signature(req, timestamp, body))
+
+	recorder := httptest.NewRecorder()
+	verifier.Middleware(http.NotFoundHandler()).ServeHTTP(recorder, req)
+	if recorder.Code != http.StatusRequestEntityTooLarge {
+		t.Fatal(recorder.Code)
+	}
+}
@@ -0,0 +1,306 @@
+package gopunch
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// UnsignedPayload
+//
+//	payload hash sent when the body is not part of the signature
+const UnsignedPayload = "UNSIGNED-PAYLOAD"
+
+// MaxPresignExpiry
+//
+//	longest validity accepted for a presigned URL
+const MaxPresignExpiry = 7 * 24 * time.Hour
+
+const (
+	sigV4Algorithm     = "AWS4-HMAC-SHA256"
+	sigV4DateFormat    = "20060102T150405Z"
+	sigV4ContentHash   = "X-Amz-Content-Sha256"
+	sigV4SecurityToken = "X-Amz-Security-Token"
+	sigV4DateHeader    = "X-Amz-Date"
+	sigV4ScopeRequest  = "aws4_request"
+)
+
+var ErrPresignExpiry = errors.New("presign expiry must be between 1 second and 7 days")
+
+// headers that proxies and transports are free to change, never signed
+var sigV4IgnoredHeaders = map[string]bool{
+	"authorization":   true,
+	"user-agent":      true,
+	"x-amzn-trace-id": true,
+	"expect":          true,
+	"connection":      true,
+}
+
+// SigV4Signer
+//
+//	signs requests with AWS Signature Version 4
+//	works for S3 compatible storage and any other SigV4 protected API
+type SigV4Signer struct {
+	accessKeyID     string
+	secretAccessKey string
+	sessionToken    string
+	region          string
+	service         string
+	unsignedPayload bool
+}
+
+// NewSigV4Signer
+//
+//	takes access key id, secret access key, region and service name such as "s3"
+//	returns *SigV4Signer, usable as Authenticator
+func NewSigV4Signer(accessKeyID, secretAccessKey, region, service string) *SigV4Signer {
+	return &SigV4Signer{
+		accessKeyID:     accessKeyID,
+		secretAccessKey: secretAccessKey,
+		region:          region,
+		service:         service,
+	}
+}
+
+// SetSessionToken
+//
+//	takes the session token of temporary credentials
+func (s *SigV4Signer) SetSessionToken(token string) {
+	s.sessionToken = token
+}
+
+// SetUnsignedPayload
+//
+//	when true bodies are not hashed and UNSIGNED-PAYLOAD is sent instead,
+//	useful for large uploads over TLS
+func (s *SigV4Signer) SetUnsignedPayload(unsigned bool) {
+	s.unsignedPayload = unsigned
+}
+
+// Authenticate
+//
+//	signs the request at the current time
+//	an X-Amz-Content-Sha256 header already set on the request is used as the payload hash
+func (s *SigV4Signer) Authenticate(req *http.Request) error {
+	payloadHash := req.Header.Get(sigV4ContentHash)
+	if payloadHash == "" {
+		var err error
+		if payloadHash, err = s.payloadHash(req); err != nil {
+			return err
+		}
+	}
+
+	return s.Sign(req, payloadHash, nowFunc())
+}
+
+// Sign
+//
+//	takes request, hex encoded SHA-256 of the body or UnsignedPayload, and signing time
+//	sets X-Amz-Date, X-Amz-Content-Sha256 and Authorization
+func (s *SigV4Signer) Sign(req *http.Request, payloadHash string, at time.Time) error {
+	at = at.UTC()
+
+	req.Header.Del("Authorization")
+	req.Header.Set(sigV4DateHeader, at.Format(sigV4DateFormat))
+	req.Header.Set(sigV4ContentHash, payloadHash)
+	if s.sessionToken != "" {
+		req.Header.Set(sigV4SecurityToken, s.sessionToken)
+	}
+
+	signedHeaders := sigV4SignedHeaders(req)
+	signature := s.signature(req, signedHeaders, payloadHash, at)
+
+	req.Header.Set("Authorization", fmt.Sprintf("%s Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		sigV4Algorithm, s.accessKeyID, s.scope(at), strings.Join(signedHeaders, ";"), signature))
+
+	return nil
+}
+
+// Presign
+//
+//	takes a GET or PUT request and how long the URL stays valid
+//	headers set on the request are signed and must be sent along with the URL
+//	the body is never signed, anyone holding the URL may upload any content
+//	returns the presigned URL
+func (s *SigV4Signer) Presign(req *http.Request, expires time.Duration) (string, error) {
+	return s.presign(req, expires, nowFunc())
+}
+
+func (s *SigV4Signer) presign(req *http.Request, expires time.Duration, at time.Time) (string, error) {
+	if req.Method != http.MethodGet && req.Method != http.MethodPut {
+		return "", fmt.Errorf("presign: unsupported method %s", req.Method)
+	}
+
+	if expires < time.Second || expires > MaxPresignExpiry {
+		return "", ErrPresignExpiry
+	}
+
+	at = at.UTC()
+	signed := req.Clone(req.Context())
+	signedHeaders := sigV4SignedHeaders(signed)
+
+	query := signed.URL.Query()
+	query.Set("X-Amz-Algorithm", sigV4Algorithm)
+	query.Set("X-Amz-Credential", s.accessKeyID+"/"+s.scope(at))
+	query.Set("X-Amz-Date", at.Format(sigV4DateFormat))
+	query.Set("X-Amz-Expires", strconv.Itoa(int(expires/time.Second)))
+	query.Set("X-Amz-SignedHeaders", strings.Join(signedHeaders, ";"))
+	if s.sessionToken != "" {
+		query.Set(sigV4SecurityToken, s.sessionToken)
+	}
+	signed.URL.RawQuery = sigV4CanonicalQuery(query)
+
+	signature := s.signature(signed, signedHeaders, UnsignedPayload, at)
+	signed.URL.RawQuery += "&X-Amz-Signature=" + signature
+
+	return signed.URL.String(), nil
+}
+
+// payloadHash hashes the body, unless the payload is unsigned
+func (s *SigV4Signer) payloadHash(req *http.Request) (string, error) {
+	if s.unsignedPayload {
+		return UnsignedPayload, nil
+	}
+
+	body, err := requestBody(req)
+	if err != nil {
+		return "", err
+	}
+
+	sum := sha256.Sum256(body)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+func (s *SigV4Signer) scope(at time.Time) string {
+	return strings.Join([]string{at.Format("20060102"), s.region, s.service, sigV4ScopeRequest}, "/")
+}
+
+func (s *SigV4Signer) signature(req *http.Request, signedHeaders []string, payloadHash string, at time.Time) string {
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		s.canonicalURI(req.URL),
+		sigV4CanonicalQuery(req.URL.Query()),
+		sigV4CanonicalHeaders(req, signedHeaders),
+		strings.Join(signedHeaders, ";"),
+		payloadHash,
+	}, "\n")
+
+	hashed := sha256.Sum256([]byte(canonicalRequest))
+	stringToSign := strings.Join([]string{
+		sigV4Algorithm,
+		at.Format(sigV4DateFormat),
+		s.scope(at),
+		hex.EncodeToString(hashed[:]),
+	}, "\n")
+
+	key := hmacSHA256([]byte("AWS4"+s.secretAccessKey), at.Format("20060102"))
+	key = hmacSHA256(key, s.region)
+	key = hmacSHA256(key, s.service)
+	key = hmacSHA256(key, sigV4ScopeRequest)
+
+	return hex.EncodeToString(hmacSHA256(key, stringToSign))
+}
+
+// canonicalURI escapes every path segment, twice for every service but S3
+func (s *SigV4Signer) canonicalURI(u *url.URL) string {
+	path := u.EscapedPath()
+	if path == "" {
+		return "/"
+	}
+
+	segments := strings.Split(path, "/")
+	for i, segment := range segments {
+		if unescaped, err := url.PathUnescape(segment); err == nil {
+			segment = unescaped
+		}
+
+		segment = sigV4Escape(segment)
+		if s.service != "s3" {
+			segment = sigV4Escape(segment)
+		}
+		segments[i] = segment
+	}
+
+	return strings.Join(segments, "/")
+}
+
+func sigV4CanonicalQuery(query url.Values) string {
+	pairs := make([]string, 0, len(query))
+	for key, values := range query {
+		for _, value := range values {
+			pairs = append(pairs, sigV4Escape(key)+"="+sigV4Escape(value))
+		}
+	}
+	sort.Strings(pairs)
+
+	return strings.Join(pairs, "&")
+}
+
+func sigV4SignedHeaders(req *http.Request) []string {
+	names := []string{"host"}
+	for name := range req.Header {
+		lower := strings.ToLower(name)
+		if lower != "host" && !sigV4IgnoredHeaders[lower] {
+			names = append(names, lower)
+		}
+	}
+	sort.Strings(names)
+
+	return names
+}
+
+func sigV4CanonicalHeaders(req *http.Request, signedHeaders []string) string {
+	var b strings.Builder
+	for _, name := range signedHeaders {
+		var value string
+		if name == "host" {
+			value = req.Host
+			if value == "" {
+				value = req.URL.Host
+			}
+		} else {
+			var values []string
+			for _, v := range req.Header.Values(name) {
+				values = append(values, strings.Join(strings.Fields(v), " "))
+			}
+			value = strings.Join(values, ",")
+		}
+
+		b.WriteString(name)
+		b.WriteString(":")
+		b.WriteString(value)
+		b.WriteString("\n")
+	}
+
+	return b.String()
+}
+
+// sigV4Escape percent encodes everything but unreserved characters, as SigV4 requires
+func sigV4Escape(s string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if 'A' <= c && c <= 'Z' || 'a' <= c && c <= 'z' || '0' <= c && c <= '9' || c == '-' || c == '_' || c == '.' || c == '~' {
+			b.WriteByte(c)
+			continue
+		}
+
+		fmt.Fprintf(&b, "%%%02X", c)
+	}
+
+	return b.String()
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
@@ -0,0 +1,122 @@
+package gopunch
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+)
+
+var AuthenticatorTestCases = []struct {
+	Title         string
+	Authenticator Authenticator
+	Check         func(r *http.Request) bool
+}{
+	{
+		Title:         "Given BasicAuth; request should carry basic credentials",
+		Authenticator: BasicAuth("user", "pass"),
+		Check: func(r *http.Request) bool {
+			username, password, ok := r.BasicAuth()
+			return ok && username == "user" && password == "pass"
+		},
+	},
+	{
+		Title:         "Given BearerToken; request should carry bearer authorization",
+		Authenticator: BearerToken("abc"),
+		Check: func(r *http.Request) bool {
+			return r.Header.Get("Authorization") == "Bearer abc"
+		},
+	},
+	{
+		Title:         "Given APIKeyHeader; request should carry the key in the header",
+		Authenticator: APIKeyHeader("X-Api-Key", "k1"),
+		Check: func(r *http.Request) bool {
+			return r.Header.Get("X-Api-Key") == "k1"
+		},
+	},
+	{
+		Title:         "Given APIKeyQuery; request should carry the key in the query, keeping existing queries",
+		Authenticator: APIKeyQuery("api_key", "k2"),
+		Check: func(r *http.Request) bool {
+			return r.URL.Query().Get("api_key") == "k2" && r.URL.Query().Get("page") == "1"
+		},
+	},
+}
+
+func Test_Authenticators(t *testing.T) {
+	for _, testCase := range AuthenticatorTestCases {
+		t.Log(testCase.Title)
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if !testCase.Check(r) {
+				w.WriteHeader(http.StatusUnauthorized)
+			}
+		}))
+
+		client := New(server.URL)
+		client.SetAuthenticator(testCase.Authenticator)
+
+		resp := client.Get(context.Background(), "/secure", WithQueries(map[string]string{"page": "1"}))
+		if resp.Err() != nil {
+			t.Fatal(resp.Err())
+		}
+
+		if resp.HttpResponse().StatusCode != http.StatusOK {
+			t.Fail()
+		}
+
+		resp.Close()
+		server.Close()
+	}
+}
+
+func Test_Authenticator_RefreshOn401(t *testing.T) {
+	t.Log("Given token rejected by the server; client should refresh once and replay the request with its body")
+	var fetches, requests int32
+	source := TokenSourceFunc(func(ctx context.Context) (*Token, error) {
+		n := atomic.AddInt32(&fetches, 1)
+		return &Token{AccessToken: fmt.Sprintf("token-%d", n)}, nil
+	})
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		body := make([]byte, 7)
+		r.Body.Read(body)
+		if r.Header.Get("Authorization") != "Bearer token-2" || string(body) != "payload" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		w.WriteHeader(http.StatusCreated)
+	}))
+	defer server.Close()
+
+	client := New(server.URL)
+	client.SetAuthenticator(NewTokenAuth(source))
+
+	resp := client.Post(context.Background(), "/items", []byte("payload"))
+	defer resp.Close()
+
+	if resp.Err() != nil {
+		t.Fatal(resp.Err())
+	}
+
+	if resp.HttpResponse().StatusCode != http.StatusCreated || fetches != 2 || requests != 2 {
+		t.Logf("status %d, fetches %d, requests %d", resp.HttpResponse().StatusCode, fetches, requests)
+		t.Fail()
+	}
+
+	t.Log("Given token that keeps being rejected; 401 should be returned after a single replay")
+	server.Config.Handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		w.WriteHeader(http.StatusUnauthorized)
+	})
+	atomic.StoreInt32(&requests, 0)
+
+	resp = client.Get(context.Background(), "/items")
+	defer resp.Close()
+
+	if resp.HttpResponse().StatusCode != http.StatusUnauthorized || requests != 2 {
+		t.Fail()
+	}
+}
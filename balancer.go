@@ -0,0 +1,415 @@
+package gopunch
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"math/rand"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// BalanceStrategy
+//
+//	how LoadBalancer picks the endpoint of each request
+type BalanceStrategy int
+
+const (
+	// BalanceRoundRobin cycles through the endpoints
+	BalanceRoundRobin BalanceStrategy = iota
+	// BalanceRandom picks an endpoint at random
+	BalanceRandom
+	// BalanceLeastOutstanding picks the endpoint with the fewest requests in flight
+	BalanceLeastOutstanding
+	// BalanceWeighted spreads requests in proportion to the endpoint weights
+	BalanceWeighted
+)
+
+// Endpoint
+//
+//	base url of a replica, Weight is only used by BalanceWeighted and defaults to 1
+type Endpoint struct {
+	URL    string
+	Weight int
+}
+
+// EndpointStatus
+//
+//	snapshot of an endpoint, as returned by LoadBalancer.Endpoints
+type EndpointStatus struct {
+	Endpoint
+	Healthy     bool
+	Outstanding int
+}
+
+// HealthCheck
+//
+//	probes an endpoint, a nil error readmits it
+type HealthCheck func(ctx context.Context, endpoint string) error
+
+type endpointState struct {
+	Endpoint
+	outstanding int
+	failures    int
+	ejected     bool
+	current     int
+}
+
+// LoadBalancer
+//
+//	spreads the requests of a Client over several base urls
+//	an endpoint is ejected after failing with an error or a 5xx response,
+//	and readmitted once it passes the health checks, see StartHealthChecks
+//	when every endpoint is ejected, all of them are used again
+//	safe for concurrent use
+type LoadBalancer struct {
+	mu          sync.Mutex
+	strategy    BalanceStrategy
+	endpoints   []*endpointState
+	next        int
+	maxFailures int
+	random      *rand.Rand
+}
+
+// NewLoadBalancer
+//
+//	takes the strategy and the endpoints
+//	returns *LoadBalancer ejecting endpoints on their first failure
+func NewLoadBalancer(strategy BalanceStrategy, endpoints ...Endpoint) *LoadBalancer {
+	b := &LoadBalancer{
+		strategy:    strategy,
+		maxFailures: 1,
+		random:      rand.New(rand.NewSource(time.Now().UnixNano())),
+	}
+	b.SetEndpoints(endpoints...)
+
+	return b
+}
+
+// SetEndpoints
+//
+//	replaces the endpoints, those already known keep their health and requests in flight
+func (b *LoadBalancer) SetEndpoints(endpoints ...Endpoint) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	known := map[string]*endpointState{}
+	for _, state := range b.endpoints {
+		known[state.URL] = state
+	}
+
+	b.endpoints = make([]*endpointState, 0, len(endpoints))
+	for _, endpoint := range endpoints {
+		endpoint.URL = strings.TrimSuffix(endpoint.URL, "/")
+		if endpoint.Weight <= 0 {
+			endpoint.Weight = 1
+		}
+
+		state, ok := known[endpoint.URL]
+		if !ok {
+			state = &endpointState{}
+		}
+		state.Endpoint = endpoint
+		b.endpoints = append(b.endpoints, state)
+	}
+}
+
+// SetMaxFailures
+//
+//	takes how many consecutive failures eject an endpoint, 1 by default
+func (b *LoadBalancer) SetMaxFailures(failures int) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.maxFailures = failures
+}
+
+// Endpoints
+//
+//	returns the state of every endpoint
+func (b *LoadBalancer) Endpoints() []EndpointStatus {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	statuses := make([]EndpointStatus, 0, len(b.endpoints))
+	for _, state := range b.endpoints {
+		statuses = append(statuses, EndpointStatus{Endpoint: state.Endpoint, Healthy: !state.ejected, Outstanding: state.outstanding})
+	}
+
+	return statuses
+}
+
+// pick selects an endpoint and counts the request as outstanding,
+// returning its url as read under the lock since SetEndpoints may rewrite the state
+func (b *LoadBalancer) pick() (*endpointState, string, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	candidates := make([]*endpointState, 0, len(b.endpoints))
+	for _, state := range b.endpoints {
+		if !state.ejected {
+			candidates = append(candidates, state)
+		}
+	}
+
+	if len(candidates) == 0 {
+		candidates = b.endpoints
+	}
+
+	if len(candidates) == 0 {
+		return nil, "", fmt.Errorf("load balancer has no endpoints")
+	}
+
+	var picked *endpointState
+	switch b.strategy {
+	case BalanceRandom:
+		picked = candidates[b.random.Intn(len(candidates))]
+	case BalanceLeastOutstanding:
+		// ties are broken round robin so idle endpoints share the load
+		offset := b.next
+		b.next++
+		for i := range candidates {
+			state := candidates[(offset+i)%len(candidates)]
+			if picked == nil || state.outstanding < picked.outstanding {
+				picked = state
+			}
+		}
+	case BalanceWeighted:
+		// smooth weighted round robin, as nginx does
+		total := 0
+		for _, state := range candidates {
+			state.current += state.Weight
+			total += state.Weight
+			if picked == nil || state.current > picked.current {
+				picked = state
+			}
+		}
+		picked.current -= total
+	default:
+		picked = candidates[b.next%len(candidates)]
+		b.next++
+	}
+
+	picked.outstanding++
+
+	return picked, picked.URL, nil
+}
+
+// report records the outcome of a request, ejecting the endpoint once it failed too often
+func (b *LoadBalancer) report(state *endpointState, failed bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if !failed {
+		state.failures = 0
+		return
+	}
+
+	state.failures++
+	if state.failures >= b.maxFailures {
+		state.ejected = true
+	}
+}
+
+func (b *LoadBalancer) done(state *endpointState) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	state.outstanding--
+}
+
+// StartHealthChecks
+//
+//	probes the ejected endpoints every interval with check and readmits those that pass,
+//	until ctx is done, does nothing when interval is not positive
+func (b *LoadBalancer) StartHealthChecks(ctx context.Context, interval time.Duration, check HealthCheck) {
+	if interval <= 0 {
+		return
+	}
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+
+			b.mu.Lock()
+			var ejected []*endpointState
+			var urls []string
+			for _, state := range b.endpoints {
+				if state.ejected {
+					ejected = append(ejected, state)
+					urls = append(urls, state.URL)
+				}
+			}
+			b.mu.Unlock()
+
+			for i, state := range ejected {
+				if check(ctx, urls[i]) != nil {
+					continue
+				}
+
+				b.mu.Lock()
+				state.ejected = false
+				state.failures = 0
+				b.mu.Unlock()
+			}
+		}
+	}()
+}
+
+// HTTPHealthCheck
+//
+//	takes the http client, such as Client.HttpClient(), and the health path
+//	returns HealthCheck passing when GET endpoint+path answers 2xx
+func HTTPHealthCheck(httpClient *http.Client, path string) HealthCheck {
+	return func(ctx context.Context, endpoint string) error {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, (&Client{}).pathFixJoin(endpoint, path), nil)
+		if err != nil {
+			return err
+		}
+
+		resp, err := httpClient.Do(req)
+		if err != nil {
+			return err
+		}
+		discard(resp)
+
+		if resp.StatusCode < 200 || resp.StatusCode > 299 {
+			return &StatusError{StatusCode: resp.StatusCode, Status: resp.Status}
+		}
+
+		return nil
+	}
+}
+
+type selectedEndpointKey struct{}
+
+// SelectedEndpoint
+//
+//	returns the endpoint a request was sent to by the load balancer,
+//	for logging from a RoundTripper or from the request of a response
+func SelectedEndpoint(ctx context.Context) (string, bool) {
+	endpoint, ok := ctx.Value(selectedEndpointKey{}).(string)
+	return endpoint, ok
+}
+
+// LoadBalancer
+//
+//	returns the *LoadBalancer, nil when every request goes to the base url
+func (c *Client) LoadBalancer() *LoadBalancer {
+	return c.loadBalancer
+}
+
+// SetLoadBalancer
+//
+//	sets the *LoadBalancer picking the endpoint of every attempt, retries may go to another endpoint
+//	the base url of each request is replaced by the endpoint, leave the base url empty to
+//	pass endpoint relative paths such as "/users"
+//	pass nil to send requests to the base url again
+func (c *Client) SetLoadBalancer(balancer *LoadBalancer) {
+	c.loadBalancer = balancer
+}
+
+// attempt sends a single attempt, to the endpoint picked by the load balancer when one is set
+func (c *Client) attempt(req *http.Request) (*http.Response, error) {
+	balancer := c.loadBalancer
+	if balancer == nil {
+		return c.roundTrip(req)
+	}
+
+	rest, ok := c.relativeToBase(req.URL)
+	if !ok {
+		return c.roundTrip(req)
+	}
+
+	state, endpoint, err := balancer.pick()
+	if err != nil {
+		return nil, err
+	}
+
+	target, err := url.Parse(joinEndpoint(endpoint, rest))
+	if err != nil {
+		balancer.done(state)
+		return nil, err
+	}
+
+	endpointReq := req.Clone(context.WithValue(req.Context(), selectedEndpointKey{}, endpoint))
+	endpointReq.URL = target
+	endpointReq.Host = ""
+
+	resp, err := c.roundTrip(endpointReq)
+	if req.Context().Err() == nil {
+		balancer.report(state, err != nil || resp.StatusCode >= http.StatusInternalServerError)
+	}
+
+	if err != nil {
+		balancer.done(state)
+		return nil, err
+	}
+
+	resp.Body = keepWritable(resp.Body, &endpointBody{ReadCloser: resp.Body, release: func() { balancer.done(state) }})
+
+	return resp, nil
+}
+
+// relativeToBase returns what follows the base url in u, false when u is not under it
+func (c *Client) relativeToBase(u *url.URL) (string, bool) {
+	base := strings.TrimSuffix(c.baseUrl, "/")
+	full := u.String()
+	if base == "" {
+		return full, !u.IsAbs()
+	}
+
+	if !strings.HasPrefix(full, base) {
+		return "", false
+	}
+
+	rest := full[len(base):]
+
+	return rest, rest == "" || rest[0] == '/' || rest[0] == '?'
+}
+
+func joinEndpoint(endpoint, rest string) string {
+	if rest == "" || rest[0] == '?' || rest[0] == '/' {
+		return endpoint + rest
+	}
+
+	return endpoint + "/" + rest
+}
+
+// endpointBody ends the outstanding request once the body is closed
+type endpointBody struct {
+	io.ReadCloser
+	once    sync.Once
+	release func()
+}
+
+func (b *endpointBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.once.Do(b.release)
+
+	return err
+}
+
+// readWriteBody keeps upgraded connections, such as websockets, writable once their body is wrapped
+type readWriteBody struct {
+	io.ReadCloser
+	io.Writer
+}
+
+func keepWritable(body, wrapped io.ReadCloser) io.ReadCloser {
+	if writer, ok := body.(io.Writer); ok {
+		return readWriteBody{ReadCloser: wrapped, Writer: writer}
+	}
+
+	return wrapped
+}
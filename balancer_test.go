@@ -0,0 +1,247 @@
+package gopunch
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func newTestReplica(name string, status *int32) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(int(atomic.LoadInt32(status)))
+		io.WriteString(w, name+" "+r.URL.RequestURI())
+	}))
+}
+
+func Test_LoadBalancerStrategies(t *testing.T) {
+	testCases := []struct {
+		Title     string
+		Strategy  BalanceStrategy
+		Endpoints []Endpoint
+		Expected  string
+	}{
+		{Title: "Given round robin; endpoints should take turns", Strategy: BalanceRoundRobin, Endpoints: []Endpoint{{URL: "a"}, {URL: "b"}, {URL: "c"}}, Expected: "abcabc"},
+		{Title: "Given weights 3 and 1; endpoints should be picked smoothly in proportion", Strategy: BalanceWeighted, Endpoints: []Endpoint{{URL: "a", Weight: 3}, {URL: "b", Weight: 1}}, Expected: "aabaaaba"},
+		{Title: "Given least outstanding; idle endpoints should be preferred", Strategy: BalanceLeastOutstanding, Endpoints: []Endpoint{{URL: "a"}, {URL: "b"}}, Expected: "abab"},
+	}
+
+	for _, testCase := range testCases {
+		t.Log(testCase.Title)
+		balancer := NewLoadBalancer(testCase.Strategy, testCase.Endpoints...)
+
+		picked := ""
+		for i := 0; i < len(testCase.Expected); i++ {
+			_, endpoint, err := balancer.pick()
+			if err != nil {
+				t.Fatal(err)
+			}
+			picked += endpoint
+		}
+
+		if picked != testCase.Expected {
+			t.Fatal(picked)
+		}
+	}
+
+	t.Log("Given least outstanding and a busy endpoint; the idle one should be picked")
+	balancer := NewLoadBalancer(BalanceLeastOutstanding, Endpoint{URL: "a"}, Endpoint{URL: "b"})
+	busy, _, _ := balancer.pick()
+	for i := 0; i < 3; i++ {
+		state, endpoint, _ := balancer.pick()
+		if state == busy {
+			t.Fatal(endpoint)
+		}
+		balancer.done(state)
+	}
+
+	t.Log("Given random; only known endpoints should be picked")
+	balancer = NewLoadBalancer(BalanceRandom, Endpoint{URL: "a"}, Endpoint{URL: "b"})
+	for i := 0; i < 10; i++ {
+		if _, endpoint, _ := balancer.pick(); endpoint != "a" && endpoint != "b" {
+			t.Fatal(endpoint)
+		}
+	}
+
+	t.Log("Given no endpoints; pick should fail")
+	if _, _, err := NewLoadBalancer(BalanceRoundRobin).pick(); err == nil {
+		t.Fail()
+	}
+}
+
+func Test_SetLoadBalancer(t *testing.T) {
+	statusA, statusB := int32(http.StatusOK), int32(http.StatusOK)
+	replicaA := newTestReplica("a", &statusA)
+	defer replicaA.Close()
+	replicaB := newTestReplica("b", &statusB)
+	defer replicaB.Close()
+
+	balancer := NewLoadBalancer(BalanceRoundRobin, Endpoint{URL: replicaA.URL + "/api/"}, Endpoint{URL: replicaB.URL + "/api"})
+	client := New("http://service")
+	client.SetLoadBalancer(balancer)
+
+	get := func() (string, string) {
+		resp := client.Get(context.Background(), "/users", WithQueries(map[string]string{"page": "2"}))
+		if resp.Err() != nil {
+			t.Fatal(resp.Err())
+		}
+		defer resp.Close()
+
+		body, _ := io.ReadAll(resp.HttpResponse().Body)
+		endpoint, _ := SelectedEndpoint(resp.HttpResponse().Request.Context())
+
+		return string(body), endpoint
+	}
+
+	t.Log("Given two endpoints; the base url should be replaced by each in turn")
+	if body, endpoint := get(); body != "a /api/users?page=2" || endpoint != replicaA.URL+"/api" {
+		t.Fatal(body, endpoint)
+	}
+	if body, endpoint := get(); body != "b /api/users?page=2" || endpoint != replicaB.URL+"/api" {
+		t.Fatal(body, endpoint)
+	}
+
+	t.Log("Given closed responses; no request should be outstanding")
+	for _, status := range balancer.Endpoints() {
+		if status.Outstanding != 0 || !status.Healthy {
+			t.Fatal(status)
+		}
+	}
+
+	t.Log("Given 5xx from an endpoint; it should be ejected and the other one used")
+	atomic.StoreInt32(&statusB, http.StatusServiceUnavailable)
+	get()
+	get()
+	if balancer.Endpoints()[1].Healthy {
+		t.Fatal("endpoint b should be ejected")
+	}
+	for i := 0; i < 2; i++ {
+		if body, _ := get(); body != "a /api/users?page=2" {
+			t.Fatal(body)
+		}
+	}
+
+	t.Log("Given every endpoint ejected; requests should still be sent")
+	atomic.StoreInt32(&statusA, http.StatusBadGateway)
+	get()
+	resp := client.Get(context.Background(), "/users")
+	if resp.Err() != nil {
+		t.Fatal(resp.Err())
+	}
+	resp.Close()
+
+	t.Log("Given passing health checks; ejected endpoints should be readmitted")
+	atomic.StoreInt32(&statusA, http.StatusOK)
+	atomic.StoreInt32(&statusB, http.StatusOK)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	balancer.StartHealthChecks(ctx, 10*time.Millisecond, HTTPHealthCheck(client.HttpClient(), "/health"))
+
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		statuses := balancer.Endpoints()
+		if statuses[0].Healthy && statuses[1].Healthy {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal(statuses)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	t.Log("Given absolute url and empty base url; it should be sent unchanged")
+	client = New("")
+	client.SetLoadBalancer(balancer)
+	resp = client.Get(context.Background(), replicaA.URL+"/direct")
+	if resp.Err() != nil {
+		t.Fatal(resp.Err())
+	}
+	defer resp.Close()
+	body, _ := io.ReadAll(resp.HttpResponse().Body)
+	if _, ok := SelectedEndpoint(resp.HttpResponse().Request.Context()); ok || string(body) != "a /direct" {
+		t.Fatal(string(body))
+	}
+}
+
+func Test_LoadBalancerFailover(t *testing.T) {
+	status := int32(http.StatusOK)
+	replica := newTestReplica("up", &status)
+	defer replica.Close()
+
+	down := httptest.NewServer(http.NotFoundHandler())
+	downURL := down.URL
+	down.Close()
+
+	balancer := NewLoadBalancer(BalanceRoundRobin, Endpoint{URL: downURL}, Endpoint{URL: replica.URL})
+	client := New("")
+	client.SetLoadBalancer(balancer)
+	client.SetRetryPolicy(&RetryPolicy{MaxAttempts: 2, Backoff: time.Millisecond})
+
+	t.Log("Given unreachable endpoint and retries; the retry should fail over to the other endpoint")
+	resp := client.Get(context.Background(), "/ping")
+	if resp.Err() != nil {
+		t.Fatal(resp.Err())
+	}
+	defer resp.Close()
+
+	body, _ := io.ReadAll(resp.HttpResponse().Body)
+	if string(body) != "up /ping" || balancer.Endpoints()[0].Healthy {
+		t.Fatal(string(body), balancer.Endpoints())
+	}
+
+	t.Log("Given failing health check; ejected endpoint should stay out")
+	ctx, cancel := context.WithCancel(context.Background())
+	checked := make(chan string, 1)
+	balancer.StartHealthChecks(ctx, time.Millisecond, func(ctx context.Context, endpoint string) error {
+		select {
+		case checked <- endpoint:
+		default:
+		}
+		return errors.New("down")
+	})
+	if endpoint := <-checked; endpoint != downURL {
+		t.Fatal(endpoint)
+	}
+	cancel()
+
+	if balancer.Endpoints()[0].Healthy {
+		t.Fail()
+	}
+
+	t.Log("Given non-positive interval; no health checks should be started")
+	balancer.StartHealthChecks(context.Background(), 0, func(ctx context.Context, endpoint string) error {
+		t.Error("unexpected health check")
+		return nil
+	})
+	time.Sleep(10 * time.Millisecond)
+}
+
+func Test_LoadBalancerConcurrentSetEndpoints(t *testing.T) {
+	status := int32(http.StatusOK)
+	replica := newTestReplica("a", &status)
+	defer replica.Close()
+
+	balancer := NewLoadBalancer(BalanceRoundRobin, Endpoint{URL: replica.URL})
+	client := New("")
+	client.SetLoadBalancer(balancer)
+
+	t.Log("Given endpoints replaced while requests are in flight; requests should keep succeeding")
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 50; i++ {
+			balancer.SetEndpoints(Endpoint{URL: replica.URL, Weight: i + 1})
+		}
+	}()
+
+	for i := 0; i < 20; i++ {
+		if body := readBody(t, client.Get(context.Background(), "/")); body != "a /" {
+			t.Fatal(body)
+		}
+	}
+	<-done
+}
@@ -0,0 +1,558 @@
+package gopunch
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DefaultMaxCacheBodySize
+//
+//	largest response body stored in the cache unless SetMaxCacheBodySize says otherwise
+const DefaultMaxCacheBodySize = 10 << 20
+
+// CacheStatusHeader
+//
+//	header added to responses served by the cache
+//	value is one of CacheHit, CacheRevalidated or CacheStale
+const CacheStatusHeader = "X-Gopunch-Cache"
+
+const (
+	CacheHit         = "HIT"
+	CacheRevalidated = "REVALIDATED"
+	CacheStale       = "STALE"
+)
+
+// Cache
+//
+//	storage used by the client to keep cacheable responses
+//	implementations must be safe for concurrent use
+type Cache interface {
+	Get(key string) ([]byte, bool)
+	Set(key string, value []byte)
+	Delete(key string)
+}
+
+// nowFunc is replaced in tests to control the clock
+var nowFunc = time.Now
+
+// backgroundRevalidateTimeout bounds the refreshes made for stale-while-revalidate
+const backgroundRevalidateTimeout = 30 * time.Second
+
+var cacheableStatusCodes = map[int]bool{
+	http.StatusOK:                   true,
+	http.StatusNonAuthoritativeInfo: true,
+	http.StatusNoContent:            true,
+	http.StatusMultipleChoices:      true,
+	http.StatusMovedPermanently:     true,
+	http.StatusNotFound:             true,
+	http.StatusMethodNotAllowed:     true,
+	http.StatusGone:                 true,
+	http.StatusRequestURITooLong:    true,
+	http.StatusNotImplemented:       true,
+}
+
+type cacheEntry struct {
+	StatusCode   int               `json:"statusCode"`
+	Header       http.Header       `json:"header"`
+	Body         []byte            `json:"body"`
+	Vary         map[string]string `json:"vary,omitempty"`
+	RequestTime  time.Time         `json:"requestTime"`
+	ResponseTime time.Time         `json:"responseTime"`
+
+	// VaryIndex and Variants are only set on the entry kept under the primary key of a response with Vary,
+	// naming the varied headers and the keys its variants are stored under
+	VaryIndex []string `json:"varyIndex,omitempty"`
+	Variants  []string `json:"variants,omitempty"`
+}
+
+func (e *cacheEntry) date() time.Time {
+	if date, err := http.ParseTime(e.Header.Get("Date")); err == nil {
+		return date
+	}
+
+	return e.ResponseTime
+}
+
+func (e *cacheEntry) freshnessLifetime() time.Duration {
+	if maxAge, ok := parseCacheControl(e.Header)["max-age"]; ok {
+		if seconds, err := parseDeltaSeconds(maxAge); err == nil {
+			return seconds
+		}
+	}
+
+	if expires := e.Header.Get("Expires"); expires != "" {
+		// an invalid Expires means the response is already expired
+		expiresAt, err := http.ParseTime(expires)
+		if err != nil {
+			return 0
+		}
+
+		return expiresAt.Sub(e.date())
+	}
+
+	// heuristic freshness, 10% of the time since the last modification
+	if lastModified, err := http.ParseTime(e.Header.Get("Last-Modified")); err == nil {
+		if date := e.date(); date.After(lastModified) {
+			return date.Sub(lastModified) / 10
+		}
+	}
+
+	return 0
+}
+
+func (e *cacheEntry) age(now time.Time) time.Duration {
+	apparentAge := e.ResponseTime.Sub(e.date())
+	if apparentAge < 0 {
+		apparentAge = 0
+	}
+
+	ageValue, _ := parseDeltaSeconds(e.Header.Get("Age"))
+	correctedAge := ageValue + e.ResponseTime.Sub(e.RequestTime)
+	if correctedAge < apparentAge {
+		correctedAge = apparentAge
+	}
+
+	return correctedAge + now.Sub(e.ResponseTime)
+}
+
+// varyNames returns the varied headers, sorted so they always build the same variant key
+func (e *cacheEntry) varyNames() []string {
+	names := make([]string, 0, len(e.Vary))
+	for name := range e.Vary {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return names
+}
+
+func (e *cacheEntry) matches(req *http.Request) bool {
+	for name, value := range e.Vary {
+		if req.Header.Get(name) != value {
+			return false
+		}
+	}
+
+	return true
+}
+
+// refreshed returns a copy of the entry updated with the headers received on a 304
+// the entry itself is left alone, it may be in use by a concurrent request
+func (e *cacheEntry) refreshed(header http.Header, requestTime, responseTime time.Time) *cacheEntry {
+	refreshed := *e
+	refreshed.Header = e.Header.Clone()
+	for key, values := range header {
+		if key == "Content-Length" {
+			continue
+		}
+
+		refreshed.Header[key] = values
+	}
+
+	refreshed.RequestTime = requestTime
+	refreshed.ResponseTime = responseTime
+
+	return &refreshed
+}
+
+func (e *cacheEntry) response(req *http.Request, status string) *http.Response {
+	header := e.Header.Clone()
+	header.Set(CacheStatusHeader, status)
+	header.Set("Age", strconv.FormatInt(int64(e.age(nowFunc())/time.Second), 10))
+
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", e.StatusCode, http.StatusText(e.StatusCode)),
+		StatusCode:    e.StatusCode,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        header,
+		Body:          io.NopCloser(bytes.NewReader(e.Body)),
+		ContentLength: int64(len(e.Body)),
+		Request:       req,
+	}
+}
+
+func cacheKey(req *http.Request) string {
+	return req.Method + " " + req.URL.String()
+}
+
+// variantKey is the secondary key of the variant selected by the varied request headers
+func variantKey(key string, vary []string, req *http.Request) string {
+	var b strings.Builder
+	b.WriteString(key)
+	for _, name := range vary {
+		b.WriteString("\n" + name + ": " + req.Header.Get(name))
+	}
+
+	return b.String()
+}
+
+// storageKey is the key entry is stored under, its variant key when the response had Vary
+func storageKey(key string, req *http.Request, entry *cacheEntry) string {
+	if len(entry.Vary) == 0 {
+		return key
+	}
+
+	return variantKey(key, entry.varyNames(), req)
+}
+
+func parseCacheControl(header http.Header) map[string]string {
+	directives := map[string]string{}
+	for _, line := range header.Values("Cache-Control") {
+		for _, part := range strings.Split(line, ",") {
+			part = strings.TrimSpace(part)
+			if part == "" {
+				continue
+			}
+
+			name, value, _ := strings.Cut(part, "=")
+			directives[strings.ToLower(strings.TrimSpace(name))] = strings.Trim(strings.TrimSpace(value), `"`)
+		}
+	}
+
+	return directives
+}
+
+func parseDeltaSeconds(value string) (time.Duration, error) {
+	seconds, err := strconv.ParseInt(strings.TrimSpace(value), 10, 64)
+	if err != nil {
+		return 0, err
+	}
+
+	if seconds < 0 {
+		return 0, fmt.Errorf("negative delta-seconds %d", seconds)
+	}
+
+	return time.Duration(seconds) * time.Second, nil
+}
+
+// allowsStale reports whether a directive such as stale-if-error covers the given staleness
+func allowsStale(directives map[string]string, name string, staleness time.Duration) bool {
+	value, ok := directives[name]
+	if !ok {
+		return false
+	}
+
+	window, err := parseDeltaSeconds(value)
+	if err != nil {
+		return false
+	}
+
+	return staleness <= window
+}
+
+func (c *Client) doCached(req *http.Request) *Response {
+	key := cacheKey(req)
+
+	if req.Method != http.MethodGet && req.Method != http.MethodHead {
+		resp, err := c.send(req)
+		if err == nil && resp.StatusCode < http.StatusBadRequest {
+			// unsafe methods invalidate what we know about the target
+			c.invalidate(http.MethodGet + " " + req.URL.String())
+			c.invalidate(http.MethodHead + " " + req.URL.String())
+		}
+
+		return NewResponse(resp, err)
+	}
+
+	reqDirectives := parseCacheControl(req.Header)
+	_, noStore := reqDirectives["no-store"]
+	conditional := req.Header.Get("If-None-Match") != "" || req.Header.Get("If-Modified-Since") != ""
+	if noStore || conditional {
+		return NewResponse(c.send(req))
+	}
+
+	entry, ok := c.loadCacheEntry(key, req)
+	if !ok {
+		return NewResponse(c.fetchAndStore(req, key))
+	}
+
+	respDirectives := parseCacheControl(entry.Header)
+	if _, public := respDirectives["public"]; !public && req.Header.Get("Authorization") != "" {
+		// the entry may belong to other credentials, only public responses are shared
+		return NewResponse(c.send(req))
+	}
+	_, reqNoCache := reqDirectives["no-cache"]
+	_, respNoCache := respDirectives["no-cache"]
+	_, mustRevalidate := respDirectives["must-revalidate"]
+
+	staleness := entry.age(nowFunc()) - entry.freshnessLifetime()
+	if !reqNoCache && !respNoCache && staleness < 0 {
+		return NewResponse(entry.response(req, CacheHit), nil)
+	}
+
+	canServeStale := !reqNoCache && !respNoCache && !mustRevalidate
+	if canServeStale && allowsStale(respDirectives, "stale-while-revalidate", staleness) {
+		stale := entry.response(req, CacheStale)
+		c.revalidateInBackground(req, storageKey(key, req, entry), key, entry)
+
+		return NewResponse(stale, nil)
+	}
+
+	resp, err := c.revalidate(req, key, entry)
+	failed := err != nil || resp.StatusCode >= http.StatusInternalServerError
+	if failed && !mustRevalidate && (allowsStale(respDirectives, "stale-if-error", staleness) ||
+		allowsStale(reqDirectives, "stale-if-error", staleness)) {
+		if err == nil {
+			resp.Body.Close()
+		}
+
+		return NewResponse(entry.response(req, CacheStale), nil)
+	}
+
+	return NewResponse(resp, err)
+}
+
+// revalidateInBackground refreshes a stale entry, once at a time per variant and within backgroundRevalidateTimeout
+func (c *Client) revalidateInBackground(req *http.Request, variant, key string, entry *cacheEntry) {
+	if _, running := c.revalidating.LoadOrStore(variant, true); running {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), backgroundRevalidateTimeout)
+	bgReq := req.Clone(ctx)
+
+	c.revalidations.Add(1)
+	go func() {
+		defer c.revalidations.Done()
+		defer c.revalidating.Delete(variant)
+		defer cancel()
+
+		resp, err := c.revalidate(bgReq, key, entry)
+		if err == nil {
+			discard(resp)
+		}
+	}()
+}
+
+func (c *Client) loadCacheEntry(key string, req *http.Request) (*cacheEntry, bool) {
+	entry, ok := c.readCacheEntry(key)
+	if ok && len(entry.VaryIndex) > 0 {
+		entry, ok = c.readCacheEntry(variantKey(key, entry.VaryIndex, req))
+	}
+
+	if !ok || !entry.matches(req) {
+		return nil, false
+	}
+
+	return entry, true
+}
+
+func (c *Client) readCacheEntry(key string) (*cacheEntry, bool) {
+	data, ok := c.cache.Get(key)
+	if !ok {
+		return nil, false
+	}
+
+	entry := &cacheEntry{}
+	if err := json.Unmarshal(data, entry); err != nil {
+		c.cache.Delete(key)
+		return nil, false
+	}
+
+	return entry, true
+}
+
+func (c *Client) writeCacheEntry(key string, entry *cacheEntry) {
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return
+	}
+
+	c.cache.Set(key, data)
+}
+
+// saveCacheEntry stores entry under key, or as a variant listed in the index kept under key when it has Vary
+func (c *Client) saveCacheEntry(key string, req *http.Request, entry *cacheEntry) {
+	index, _ := c.readCacheEntry(key)
+	if len(entry.Vary) == 0 {
+		if index != nil {
+			c.dropVariants(index)
+		}
+		c.writeCacheEntry(key, entry)
+		return
+	}
+
+	vary := entry.varyNames()
+	variant := variantKey(key, vary, req)
+
+	// a different set of varied headers makes the previous variants unreachable
+	if index == nil || strings.Join(index.VaryIndex, ",") != strings.Join(vary, ",") {
+		if index != nil {
+			c.dropVariants(index)
+		}
+		index = &cacheEntry{VaryIndex: vary}
+	}
+
+	known := false
+	for _, stored := range index.Variants {
+		known = known || stored == variant
+	}
+	if !known {
+		index.Variants = append(index.Variants, variant)
+		c.writeCacheEntry(key, index)
+	}
+
+	c.writeCacheEntry(variant, entry)
+}
+
+func (c *Client) dropVariants(index *cacheEntry) {
+	for _, variant := range index.Variants {
+		c.cache.Delete(variant)
+	}
+}
+
+// invalidate removes what is cached under key, variants included
+func (c *Client) invalidate(key string) {
+	if index, ok := c.readCacheEntry(key); ok {
+		c.dropVariants(index)
+	}
+
+	c.cache.Delete(key)
+}
+
+func (c *Client) revalidate(req *http.Request, key string, entry *cacheEntry) (*http.Response, error) {
+	condReq := req.Clone(req.Context())
+	if etag := entry.Header.Get("ETag"); etag != "" {
+		condReq.Header.Set("If-None-Match", etag)
+	}
+
+	if lastModified := entry.Header.Get("Last-Modified"); lastModified != "" {
+		condReq.Header.Set("If-Modified-Since", lastModified)
+	}
+
+	requestTime := nowFunc()
+	resp, err := c.send(condReq)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode != http.StatusNotModified {
+		resp.Request = req
+		return c.storeResponse(req, key, resp, requestTime)
+	}
+
+	io.Copy(io.Discard, resp.Body)
+	resp.Body.Close()
+
+	entry = entry.refreshed(resp.Header, requestTime, nowFunc())
+	c.saveCacheEntry(key, req, entry)
+
+	return entry.response(req, CacheRevalidated), nil
+}
+
+func (c *Client) fetchAndStore(req *http.Request, key string) (*http.Response, error) {
+	requestTime := nowFunc()
+	resp, err := c.send(req)
+	if err != nil {
+		return nil, err
+	}
+
+	return c.storeResponse(req, key, resp, requestTime)
+}
+
+func (c *Client) storeResponse(req *http.Request, key string, resp *http.Response, requestTime time.Time) (*http.Response, error) {
+	if !isStorable(req, resp) {
+		return resp, nil
+	}
+
+	limit := c.MaxCacheBodySize()
+	if resp.ContentLength > limit {
+		return resp, nil
+	}
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, limit+1))
+	if err != nil {
+		resp.Body.Close()
+		return nil, err
+	}
+
+	if int64(len(body)) > limit {
+		// too large to keep, hand back what was read followed by the rest of the stream
+		resp.Body = readCloser{Reader: io.MultiReader(bytes.NewReader(body), resp.Body), Closer: resp.Body}
+		return resp, nil
+	}
+
+	resp.Body.Close()
+	resp.Body = io.NopCloser(bytes.NewReader(body))
+
+	entry := &cacheEntry{
+		StatusCode:   resp.StatusCode,
+		Header:       resp.Header.Clone(),
+		Body:         body,
+		RequestTime:  requestTime,
+		ResponseTime: nowFunc(),
+	}
+
+	for _, line := range resp.Header.Values("Vary") {
+		for _, name := range strings.Split(line, ",") {
+			name = http.CanonicalHeaderKey(strings.TrimSpace(name))
+			if name == "" {
+				continue
+			}
+
+			if entry.Vary == nil {
+				entry.Vary = map[string]string{}
+			}
+
+			entry.Vary[name] = req.Header.Get(name)
+		}
+	}
+
+	c.saveCacheEntry(key, req, entry)
+
+	return resp, nil
+}
+
+func isStorable(req *http.Request, resp *http.Response) bool {
+	if !cacheableStatusCodes[resp.StatusCode] {
+		return false
+	}
+
+	// event streams never end, there is no body to store
+	if mediaType, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type")); mediaType == "text/event-stream" {
+		return false
+	}
+
+	if _, ok := parseCacheControl(req.Header)["no-store"]; ok {
+		return false
+	}
+
+	respDirectives := parseCacheControl(resp.Header)
+	if _, ok := respDirectives["no-store"]; ok {
+		return false
+	}
+
+	// responses to authorized requests are only stored when marked public, RFC 9111 section 3.5
+	if _, public := respDirectives["public"]; !public && req.Header.Get("Authorization") != "" {
+		return false
+	}
+
+	for _, line := range resp.Header.Values("Vary") {
+		if strings.TrimSpace(line) == "*" {
+			return false
+		}
+	}
+
+	_, hasMaxAge := respDirectives["max-age"]
+
+	return hasMaxAge ||
+		resp.Header.Get("Expires") != "" ||
+		resp.Header.Get("ETag") != "" ||
+		resp.Header.Get("Last-Modified") != ""
+}
+
+type readCloser struct {
+	io.Reader
+	io.Closer
+}
@@ -0,0 +1,75 @@
+package gopunch
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+)
+
+// DiskCache
+//
+//	Cache that keeps every entry as a file inside a directory
+type DiskCache struct {
+	dir string
+}
+
+// NewDiskCache
+//
+//	takes a directory, creating it if it does not exist
+//	returns *DiskCache, error
+func NewDiskCache(dir string) (*DiskCache, error) {
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, err
+	}
+
+	return &DiskCache{dir: dir}, nil
+}
+
+func (d *DiskCache) path(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return filepath.Join(d.dir, hex.EncodeToString(sum[:]))
+}
+
+// Get
+//
+//	returns the value stored for key
+func (d *DiskCache) Get(key string) ([]byte, bool) {
+	data, err := os.ReadFile(d.path(key))
+	if err != nil {
+		return nil, false
+	}
+
+	return data, true
+}
+
+// Set
+//
+//	stores value for key, writes are atomic so readers never see partial entries
+func (d *DiskCache) Set(key string, value []byte) {
+	tmp, err := os.CreateTemp(d.dir, "tmp-*")
+	if err != nil {
+		return
+	}
+
+	_, err = tmp.Write(value)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+
+	if err != nil {
+		os.Remove(tmp.Name())
+		return
+	}
+
+	if err := os.Rename(tmp.Name(), d.path(key)); err != nil {
+		os.Remove(tmp.Name())
+	}
+}
+
+// Delete
+//
+//	removes the value stored for key
+func (d *DiskCache) Delete(key string) {
+	os.Remove(d.path(key))
+}
@@ -0,0 +1,26 @@
+package gopunch
+
+import "testing"
+
+func Test_DiskCache(t *testing.T) {
+	t.Log("Given disk cache; set, get and delete should round trip through files")
+	cache, err := NewDiskCache(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, ok := cache.Get("missing"); ok {
+		t.Fail()
+	}
+
+	cache.Set("GET http://example.com/a", []byte("value"))
+	value, ok := cache.Get("GET http://example.com/a")
+	if !ok || string(value) != "value" {
+		t.Fail()
+	}
+
+	cache.Delete("GET http://example.com/a")
+	if _, ok := cache.Get("GET http://example.com/a"); ok {
+		t.Fail()
+	}
+}
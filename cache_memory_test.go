@@ -0,0 +1,34 @@
+package gopunch
+
+import "testing"
+
+func Test_MemoryCache(t *testing.T) {
+	t.Log("Given memory cache with capacity 2; storing 3 keys should evict the least recently used one")
+	cache := NewMemoryCache(2)
+	cache.Set("a", []byte("1"))
+	cache.Set("b", []byte("2"))
+
+	if _, ok := cache.Get("a"); !ok {
+		t.Fatal("expected a to be cached")
+	}
+
+	cache.Set("c", []byte("3"))
+
+	if _, ok := cache.Get("b"); ok {
+		t.Log("b should have been evicted")
+		t.Fail()
+	}
+
+	if value, ok := cache.Get("a"); !ok || string(value) != "1" {
+		t.Fail()
+	}
+
+	if cache.Len() != 2 {
+		t.Fail()
+	}
+
+	cache.Delete("a")
+	if _, ok := cache.Get("a"); ok {
+		t.Fail()
+	}
+}
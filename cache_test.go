@@ -0,0 +1,468 @@
+package gopunch
+
+import (
+	"bufio"
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func withFrozenClock(t *testing.T, at time.Time) *time.Time {
+	current := at
+	previous := nowFunc
+	nowFunc = func() time.Time { return current }
+	t.Cleanup(func() { nowFunc = previous })
+
+	return &current
+}
+
+func readBody(t *testing.T, resp *Response) string {
+	var body string
+	err := resp.WithUnmarshal(func(reader io.Reader) error {
+		b, err := io.ReadAll(reader)
+		body = string(b)
+		return err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return body
+}
+
+func Test_Cache_Fresh(t *testing.T) {
+	t.Log("Given response with max-age; second request should be served from cache without hitting the server")
+	var hits int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&hits, 1)
+		w.Header().Set("Cache-Control", "max-age=60")
+		w.Write([]byte("hello"))
+	}))
+	defer server.Close()
+
+	client := New(server.URL)
+	client.SetCache(NewMemoryCache(10))
+	ctx := context.Background()
+
+	for i := 0; i < 2; i++ {
+		resp := client.Get(ctx, "/fresh")
+		if readBody(t, resp) != "hello" {
+			t.Fail()
+		}
+		resp.Close()
+	}
+
+	if atomic.LoadInt32(&hits) != 1 {
+		t.Logf("expected 1 hit, got %d", hits)
+		t.Fail()
+	}
+}
+
+func Test_Cache_Revalidate(t *testing.T) {
+	t.Log("Given stale response with ETag; request should revalidate and turn 304 into the cached response")
+	now := withFrozenClock(t, time.Now())
+	var conditional int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Cache-Control", "max-age=1")
+		w.Header().Set("ETag", `"v1"`)
+		if r.Header.Get("If-None-Match") == `"v1"` {
+			atomic.AddInt32(&conditional, 1)
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Write([]byte("body"))
+	}))
+	defer server.Close()
+
+	client := New(server.URL)
+	client.SetCache(NewMemoryCache(10))
+	ctx := context.Background()
+
+	resp := client.Get(ctx, "/etag")
+	readBody(t, resp)
+	resp.Close()
+
+	*now = now.Add(5 * time.Second)
+
+	resp = client.Get(ctx, "/etag")
+	defer resp.Close()
+
+	if readBody(t, resp) != "body" {
+		t.Fail()
+	}
+
+	if resp.HttpResponse().StatusCode != http.StatusOK {
+		t.Fail()
+	}
+
+	if resp.HttpResponse().Header.Get(CacheStatusHeader) != CacheRevalidated {
+		t.Fail()
+	}
+
+	if atomic.LoadInt32(&conditional) != 1 {
+		t.Fail()
+	}
+}
+
+func Test_Cache_Vary(t *testing.T) {
+	t.Log("Given response with Vary: Accept; request with a different Accept header should not be served from cache")
+	var hits int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&hits, 1)
+		w.Header().Set("Cache-Control", "max-age=60")
+		w.Header().Set("Vary", "Accept")
+		w.Write([]byte(r.Header.Get("Accept")))
+	}))
+	defer server.Close()
+
+	client := New(server.URL)
+	client.SetCache(NewMemoryCache(10))
+	ctx := context.Background()
+
+	for _, accept := range []string{"text/plain", "text/plain", "application/json"} {
+		resp := client.Get(ctx, "/vary", WithHeaders(map[string]string{"Accept": accept}))
+		if readBody(t, resp) != accept {
+			t.Fail()
+		}
+		resp.Close()
+	}
+
+	if atomic.LoadInt32(&hits) != 2 {
+		t.Logf("expected 2 hits, got %d", hits)
+		t.Fail()
+	}
+}
+
+func Test_Cache_StaleIfError(t *testing.T) {
+	t.Log("Given stale response with stale-if-error; server error should return the stale cached response")
+	now := withFrozenClock(t, time.Now())
+	var failing int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.LoadInt32(&failing) == 1 {
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		}
+		w.Header().Set("Cache-Control", "max-age=1, stale-if-error=60")
+		w.Write([]byte("ok"))
+	}))
+	defer server.Close()
+
+	client := New(server.URL)
+	client.SetCache(NewMemoryCache(10))
+	ctx := context.Background()
+
+	resp := client.Get(ctx, "/flaky")
+	readBody(t, resp)
+	resp.Close()
+
+	atomic.StoreInt32(&failing, 1)
+	*now = now.Add(10 * time.Second)
+
+	resp = client.Get(ctx, "/flaky")
+	defer resp.Close()
+
+	if readBody(t, resp) != "ok" {
+		t.Fail()
+	}
+
+	if resp.HttpResponse().Header.Get(CacheStatusHeader) != CacheStale {
+		t.Fail()
+	}
+}
+
+func Test_Cache_StaleWhileRevalidate(t *testing.T) {
+	t.Log("Given stale response with stale-while-revalidate; request should be served stale and refreshed in background")
+	now := withFrozenClock(t, time.Now())
+	var hits int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&hits, 1)
+		w.Header().Set("Date", nowFunc().UTC().Format(http.TimeFormat))
+		w.Header().Set("Cache-Control", "max-age=1, stale-while-revalidate=60")
+		w.Write([]byte("v"))
+	}))
+	defer server.Close()
+
+	client := New(server.URL)
+	client.SetCache(NewMemoryCache(10))
+	ctx := context.Background()
+
+	resp := client.Get(ctx, "/swr")
+	readBody(t, resp)
+	resp.Close()
+
+	*now = now.Add(5 * time.Second)
+
+	for i := 0; i < 3; i++ {
+		resp = client.Get(ctx, "/swr")
+		status := resp.HttpResponse().Header.Get(CacheStatusHeader)
+		resp.Close()
+
+		if status != CacheStale && status != CacheHit {
+			t.Fatal(status)
+		}
+	}
+
+	client.revalidations.Wait()
+
+	t.Log("Given several stale hits; a single background revalidation should run")
+	if atomic.LoadInt32(&hits) != 2 {
+		t.Fatal(hits)
+	}
+
+	resp = client.Get(ctx, "/swr")
+	status := resp.HttpResponse().Header.Get(CacheStatusHeader)
+	resp.Close()
+
+	if status != CacheHit {
+		t.Fatal("background revalidation did not refresh the cache")
+	}
+}
+
+func Test_Cache_StaleWhileRevalidate_NotModified(t *testing.T) {
+	t.Log("Given stale response with ETag and stale-while-revalidate; concurrent stale hits should be served while a 304 refreshes the entry")
+	now := withFrozenClock(t, time.Now())
+	var hits, notModified int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&hits, 1)
+		w.Header().Set("Date", nowFunc().UTC().Format(http.TimeFormat))
+		w.Header().Set("Cache-Control", "max-age=1, stale-while-revalidate=60")
+		w.Header().Set("ETag", `"v1"`)
+		if r.Header.Get("If-None-Match") == `"v1"` {
+			atomic.AddInt32(&notModified, 1)
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Write([]byte("v1"))
+	}))
+	defer server.Close()
+
+	client := New(server.URL)
+	client.SetCache(NewMemoryCache(10))
+	ctx := context.Background()
+
+	readBody(t, client.Get(ctx, "/swr-etag"))
+
+	*now = now.Add(5 * time.Second)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			resp := client.Get(ctx, "/swr-etag")
+			if body, _ := io.ReadAll(resp.HttpResponse().Body); string(body) != "v1" {
+				t.Error(string(body))
+			}
+			resp.Close()
+		}()
+	}
+	wg.Wait()
+	client.revalidations.Wait()
+
+	if atomic.LoadInt32(&notModified) != 1 || atomic.LoadInt32(&hits) != 2 {
+		t.Fatal(hits, notModified)
+	}
+
+	t.Log("Given entry refreshed by the 304; it should be fresh again")
+	resp := client.Get(ctx, "/swr-etag")
+	status := resp.HttpResponse().Header.Get(CacheStatusHeader)
+	if body := readBody(t, resp); status != CacheHit || body != "v1" {
+		t.Fatal(status, body)
+	}
+}
+
+func Test_Cache_NoStore_And_Invalidation(t *testing.T) {
+	t.Log("Given no-store responses are never cached and unsafe methods invalidate cached entries")
+	var hits int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&hits, 1)
+		if r.URL.Path == "/nostore" {
+			w.Header().Set("Cache-Control", "no-store")
+		} else {
+			w.Header().Set("Cache-Control", "max-age=60")
+		}
+	}))
+	defer server.Close()
+
+	cache := NewMemoryCache(10)
+	client := New(server.URL)
+	client.SetCache(cache)
+	ctx := context.Background()
+
+	client.Get(ctx, "/nostore").Close()
+	if cache.Len() != 0 {
+		t.Fail()
+	}
+
+	client.Get(ctx, "/item").Close()
+	if cache.Len() != 1 {
+		t.Fail()
+	}
+
+	client.Put(ctx, "/item", []byte("{}")).Close()
+	if cache.Len() != 0 {
+		t.Fail()
+	}
+}
+
+func Test_Cache_Authorization(t *testing.T) {
+	var hits int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&hits, 1)
+		if r.URL.Path == "/public" {
+			w.Header().Set("Cache-Control", "public, max-age=60")
+		} else {
+			w.Header().Set("Cache-Control", "max-age=60")
+		}
+		io.WriteString(w, r.Header.Get("Authorization"))
+	}))
+	defer server.Close()
+
+	client := New(server.URL)
+	client.SetCache(NewMemoryCache(10))
+	ctx := context.Background()
+	as := func(token string) Option {
+		return WithHeaders(map[string]string{"Authorization": "Bearer " + token})
+	}
+
+	t.Log("Given two users on one client; each should get their own response")
+	for _, token := range []string{"alice", "bob", "alice"} {
+		if body := readBody(t, client.Get(ctx, "/me", as(token))); body != "Bearer "+token {
+			t.Fatal(body)
+		}
+	}
+	if atomic.LoadInt32(&hits) != 3 {
+		t.Fatal(hits)
+	}
+
+	t.Log("Given cached response without credentials; it should not be served to an authorized request unless public")
+	readBody(t, client.Get(ctx, "/me"))
+	if body := readBody(t, client.Get(ctx, "/me", as("bob"))); body != "Bearer bob" {
+		t.Fatal(body)
+	}
+
+	t.Log("Given public response to an authorized request; it should be shared")
+	atomic.StoreInt32(&hits, 0)
+	readBody(t, client.Get(ctx, "/public", as("alice")))
+	resp := client.Get(ctx, "/public", as("bob"))
+	status := resp.HttpResponse().Header.Get(CacheStatusHeader)
+	resp.Close()
+	if status != CacheHit || atomic.LoadInt32(&hits) != 1 {
+		t.Fatal(status, hits)
+	}
+}
+
+func Test_Cache_BodyLimits(t *testing.T) {
+	var hits int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&hits, 1)
+		w.Header().Set("Cache-Control", "max-age=60")
+		w.Header().Set("ETag", `"v1"`)
+
+		switch r.URL.Path {
+		case "/events":
+			w.Header().Set("Content-Type", "text/event-stream")
+			io.WriteString(w, "data: first\n\n")
+			w.(http.Flusher).Flush()
+			stall(r)
+		case "/chunked":
+			io.WriteString(w, "0123456789")
+			w.(http.Flusher).Flush()
+			io.WriteString(w, "abcdefghij")
+		default:
+			io.WriteString(w, "small")
+		}
+	}))
+	defer server.Close()
+
+	client := New(server.URL)
+	client.SetCache(NewMemoryCache(10))
+	client.SetMaxCacheBodySize(8)
+	ctx := context.Background()
+
+	t.Log("Given body over the limit; it should be returned whole and not cached")
+	for i := 0; i < 2; i++ {
+		if body := readBody(t, client.Get(ctx, "/chunked")); body != "0123456789abcdefghij" {
+			t.Fatal(body)
+		}
+	}
+	if atomic.LoadInt32(&hits) != 2 {
+		t.Fatal(hits)
+	}
+
+	t.Log("Given body within the limit; it should be cached")
+	atomic.StoreInt32(&hits, 0)
+	for i := 0; i < 2; i++ {
+		if body := readBody(t, client.Get(ctx, "/small")); body != "small" {
+			t.Fatal(body)
+		}
+	}
+	if atomic.LoadInt32(&hits) != 1 {
+		t.Fatal(hits)
+	}
+
+	t.Log("Given event stream with an ETag; it should be streamed without waiting for the end")
+	client.SetMaxCacheBodySize(0)
+	resp := client.Get(ctx, "/events")
+	if resp.Err() != nil {
+		t.Fatal(resp.Err())
+	}
+	defer resp.Close()
+
+	line, err := bufio.NewReader(resp.HttpResponse().Body).ReadString('\n')
+	if err != nil || line != "data: first\n" {
+		t.Fatal(line, err)
+	}
+}
+
+func Test_Cache_VaryVariants(t *testing.T) {
+	var hits int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			return
+		}
+
+		atomic.AddInt32(&hits, 1)
+		w.Header().Set("Cache-Control", "max-age=60")
+		w.Header().Set("Vary", "Accept-Language")
+		io.WriteString(w, r.Header.Get("Accept-Language"))
+	}))
+	defer server.Close()
+
+	client := New(server.URL)
+	client.SetCache(NewMemoryCache(10))
+	ctx := context.Background()
+
+	get := func(language string) {
+		resp := client.Get(ctx, "/greeting", WithHeaders(map[string]string{"Accept-Language": language}))
+		if body := readBody(t, resp); body != language {
+			t.Fatal(body)
+		}
+	}
+
+	t.Log("Given alternating Accept-Language; each variant should be cached side by side")
+	for _, language := range []string{"en", "fr", "en", "fr"} {
+		get(language)
+	}
+	if atomic.LoadInt32(&hits) != 2 {
+		t.Fatal(hits)
+	}
+
+	t.Log("Given unsafe request to the url; every variant should be invalidated")
+	resp := client.Post(ctx, "/greeting", nil)
+	if resp.Err() != nil {
+		t.Fatal(resp.Err())
+	}
+	resp.Close()
+
+	get("en")
+	get("fr")
+	if atomic.LoadInt32(&hits) != 4 {
+		t.Fatal(hits)
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"context"
 	"net/http"
+	"sync"
 	"time"
 )
 
@@ -11,8 +12,18 @@ import (
 //
 //	has baseURL and *http.Client
 type Client struct {
-	baseUrl    string
-	httpClient *http.Client
+	baseUrl       string
+	httpClient    *http.Client
+	cache         Cache
+	maxCacheBody  int64
+	retryPolicy   *RetryPolicy
+	authenticator Authenticator
+	dialer        *hostDialer
+	loadBalancer  *LoadBalancer
+
+	// stale-while-revalidate refreshes in flight, by cache key
+	revalidating  sync.Map
+	revalidations sync.WaitGroup
 }
 
 // New
@@ -39,6 +50,10 @@ func NewWithTimeOut(baseUrl string, timeout time.Duration) *Client {
 }
 
 func (c *Client) pathFixJoin(base, path string) string {
+	if path == "" || base == "" {
+		return base + path
+	}
+
 	if base[len(base)-1] == '/' {
 		base = base[:len(base)-1]
 	}
@@ -76,6 +91,100 @@ func (c *Client) HttpClient() *http.Client {
 //	sets the *http.Client
 func (c *Client) SetHttpClient(httpClient *http.Client) {
 	c.httpClient = httpClient
+	c.dialer = nil
+}
+
+// Cache
+//
+//	returns the Cache, nil when caching is disabled
+func (c *Client) Cache() Cache {
+	return c.cache
+}
+
+// SetCache
+//
+//	sets the Cache used to store and serve responses
+//	responses to requests carrying Authorization are only cached and served when marked public
+//	pass nil to disable caching
+func (c *Client) SetCache(cache Cache) {
+	c.cache = cache
+}
+
+// MaxCacheBodySize
+//
+//	returns the largest body stored in the cache, DefaultMaxCacheBodySize unless set
+func (c *Client) MaxCacheBodySize() int64 {
+	if c.maxCacheBody <= 0 {
+		return DefaultMaxCacheBodySize
+	}
+
+	return c.maxCacheBody
+}
+
+// SetMaxCacheBodySize
+//
+//	sets the largest body stored in the cache, larger responses are streamed through uncached
+func (c *Client) SetMaxCacheBodySize(size int64) {
+	c.maxCacheBody = size
+}
+
+// RetryPolicy
+//
+//	returns the *RetryPolicy, nil when requests are not retried
+func (c *Client) RetryPolicy() *RetryPolicy {
+	return c.retryPolicy
+}
+
+// SetRetryPolicy
+//
+//	sets the *RetryPolicy used to retry failed requests
+//	pass nil to disable retries
+func (c *Client) SetRetryPolicy(policy *RetryPolicy) {
+	c.retryPolicy = policy
+}
+
+// Authenticator
+//
+//	returns the Authenticator, nil when requests are sent as they are
+func (c *Client) Authenticator() Authenticator {
+	return c.authenticator
+}
+
+// SetAuthenticator
+//
+//	sets the Authenticator applied before each request
+//	pass nil to disable it
+func (c *Client) SetAuthenticator(authenticator Authenticator) {
+	c.authenticator = authenticator
+}
+
+// Session
+//
+//	returns the *SessionJar, nil when cookies are not kept
+func (c *Client) Session() *SessionJar {
+	jar, _ := c.httpClient.Jar.(*SessionJar)
+	return jar
+}
+
+// SetSession
+//
+//	sets the *SessionJar keeping cookies between requests, such as a login cookie
+//	pass nil to stop keeping cookies
+func (c *Client) SetSession(jar *SessionJar) {
+	if jar == nil {
+		c.httpClient.Jar = nil
+		return
+	}
+
+	c.httpClient.Jar = jar
+}
+
+func (c *Client) do(req *http.Request) *Response {
+	if c.cache != nil {
+		return c.doCached(req)
+	}
+
+	return NewResponse(c.send(req))
 }
 
 // Get
@@ -94,7 +203,7 @@ func (c *Client) Get(ctx context.Context, endPoint string, opts ...Option) *Resp
 		opt(req)
 	}
 
-	return NewResponse(c.httpClient.Do(req))
+	return c.do(req)
 }
 
 // GetUnmarshal
@@ -124,7 +233,7 @@ func (c *Client) Post(ctx context.Context, endPoint string, payload []byte, opts
 		opt(req)
 	}
 
-	return NewResponse(c.httpClient.Do(req))
+	return c.do(req)
 }
 
 // PostUnmarshal
@@ -153,7 +262,7 @@ func (c *Client) Delete(ctx context.Context, endPoint string, opts ...Option) *R
 		opt(req)
 	}
 
-	return NewResponse(c.httpClient.Do(req))
+	return c.do(req)
 }
 
 // DeleteUnmarshal
@@ -182,7 +291,7 @@ func (c *Client) Put(ctx context.Context, endPoint string, payload []byte, opts
 		opt(req)
 	}
 
-	return NewResponse(c.httpClient.Do(req))
+	return c.do(req)
 }
 
 // PutUnmarshal
@@ -211,7 +320,7 @@ func (c *Client) Patch(ctx context.Context, endPoint string, payload []byte, opt
 		opt(req)
 	}
 
-	return NewResponse(c.httpClient.Do(req))
+	return c.do(req)
 }
 
 // PatchUnmarshal
@@ -240,7 +349,7 @@ func (c *Client) Custom(ctx context.Context, method, endPoint string, payload []
 		opt(req)
 	}
 
-	return NewResponse(c.httpClient.Do(req))
+	return c.do(req)
 }
 
 // CustomUnmarshal
@@ -792,3 +792,15 @@ func Test_CustomUnmarshal(t *testing.T) {
 		}
 	}
 }
+
+func Test_PathFixJoin(t *testing.T) {
+	t.Log("Given empty endpoint; joining should return the base url as is instead of panicking")
+	client := New(BaseURL)
+	if joined := client.pathFixJoin(BaseURL+"/todos", ""); joined != BaseURL+"/todos" {
+		t.Fail()
+	}
+
+	if joined := client.pathFixJoin(BaseURL+"/", "/todos"); joined != BaseURL+"/todos" {
+		t.Fail()
+	}
+}
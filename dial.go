@@ -0,0 +1,53 @@
+package gopunch
+
+import (
+	"context"
+	"net"
+	"net/http"
+)
+
+// DialContextFunc
+//
+//	opens the connection for a request, as http.Transport.DialContext does
+type DialContextFunc func(ctx context.Context, network, addr string) (net.Conn, error)
+
+// NewUnix
+//
+//	takes the path of a unix domain socket and the base url, such as "http://localhost/v1.43"
+//	every request is sent over the socket whatever host the url names, proxies are not used
+//	returns a new *gopunch.Client
+func NewUnix(socketPath, baseUrl string) *Client {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = nil
+	transport.DialContext = unixDialer(socketPath)
+
+	return &Client{
+		baseUrl:    baseUrl,
+		httpClient: &http.Client{Transport: transport},
+	}
+}
+
+func unixDialer(socketPath string) DialContextFunc {
+	dialer := &net.Dialer{}
+
+	return func(ctx context.Context, network, addr string) (net.Conn, error) {
+		return dialer.DialContext(ctx, "unix", socketPath)
+	}
+}
+
+// SetDialContext
+//
+//	sets the function opening connections, for example to reach services through an ssh tunnel
+//	host overrides and resolvers still pick the address handed to it
+//	pass nil to use the default net.Dialer
+//	returns error if the client has a custom RoundTripper
+func (c *Client) SetDialContext(dial DialContextFunc) error {
+	dialer, err := c.hostDialer()
+	if err != nil {
+		return err
+	}
+
+	dialer.dial = dial
+
+	return nil
+}
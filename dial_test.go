@@ -0,0 +1,87 @@
+package gopunch
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+)
+
+func Test_NewUnix(t *testing.T) {
+	socketPath := filepath.Join(t.TempDir(), "daemon.sock")
+	listener, err := net.Listen("unix", socketPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	server := &http.Server{Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		fmt.Fprintf(w, "%s %s %s %s", r.Method, r.Host, r.URL.RequestURI(), body)
+	})}
+	go server.Serve(listener)
+	defer server.Close()
+
+	client := NewUnix(socketPath, "http://docker/v1.43")
+
+	t.Log("Given unix socket client; Get should join the base url and reach the daemon")
+	resp := client.Get(context.Background(), "/containers/json", WithQueries(map[string]string{"all": "1"}))
+	if resp.Err() != nil {
+		t.Fatal(resp.Err())
+	}
+
+	body, _ := io.ReadAll(resp.HttpResponse().Body)
+	resp.Close()
+	if string(body) != "GET docker /v1.43/containers/json?all=1 " {
+		t.Fatal(string(body))
+	}
+
+	t.Log("Given unix socket client; Post should send the payload over the socket")
+	resp = client.Post(context.Background(), "containers/create", []byte(`{"Image":"alpine"}`))
+	if resp.Err() != nil {
+		t.Fatal(resp.Err())
+	}
+
+	body, _ = io.ReadAll(resp.HttpResponse().Body)
+	resp.Close()
+	if string(body) != `POST docker /v1.43/containers/create {"Image":"alpine"}` {
+		t.Fatal(string(body))
+	}
+}
+
+func Test_SetDialContext(t *testing.T) {
+	t.Log("Given custom dialer; connections should be opened by it whatever host the url names")
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, r.Host)
+	}))
+	defer server.Close()
+
+	var dials int32
+	client := New("http://service.invalid")
+	client.SetProxyFunc(nil)
+	err := client.SetDialContext(func(ctx context.Context, network, addr string) (net.Conn, error) {
+		atomic.AddInt32(&dials, 1)
+		if addr != "service.invalid:80" {
+			return nil, fmt.Errorf("unexpected address %s", addr)
+		}
+		return (&net.Dialer{}).DialContext(ctx, network, server.Listener.Addr().String())
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	resp := client.Get(context.Background(), "/")
+	if resp.Err() != nil {
+		t.Fatal(resp.Err())
+	}
+
+	body, _ := io.ReadAll(resp.HttpResponse().Body)
+	resp.Close()
+	if string(body) != "service.invalid" || atomic.LoadInt32(&dials) != 1 {
+		t.Fatal(string(body), dials)
+	}
+}
@@ -0,0 +1,217 @@
+// Package graphql sends GraphQL operations through a *gopunch.Client.
+package graphql
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/haquenafeem/gopunch"
+)
+
+// Location
+//
+//	position of an error inside the operation document
+type Location struct {
+	Line   int `json:"line"`
+	Column int `json:"column"`
+}
+
+// Error
+//
+//	single entry of the GraphQL errors array
+type Error struct {
+	Message    string                 `json:"message"`
+	Path       []interface{}          `json:"path,omitempty"`
+	Locations  []Location             `json:"locations,omitempty"`
+	Extensions map[string]interface{} `json:"extensions,omitempty"`
+}
+
+func (e *Error) Error() string {
+	return e.Message
+}
+
+// Errors
+//
+//	errors array returned along with, or instead of, data
+//	get it with errors.As and index it to reach each *Error
+type Errors []*Error
+
+func (e Errors) Error() string {
+	messages := make([]string, len(e))
+	for i, err := range e {
+		messages[i] = err.Message
+	}
+
+	return "graphql: " + strings.Join(messages, "; ")
+}
+
+type request struct {
+	Query         string                 `json:"query,omitempty"`
+	OperationName string                 `json:"operationName,omitempty"`
+	Variables     map[string]interface{} `json:"variables,omitempty"`
+	Extensions    map[string]interface{} `json:"extensions,omitempty"`
+}
+
+type response struct {
+	Data   json.RawMessage `json:"data"`
+	Errors Errors          `json:"errors"`
+}
+
+// Client
+//
+//	sends queries and mutations to a single GraphQL endpoint
+type Client struct {
+	client    *gopunch.Client
+	endPoint  string
+	persisted bool
+}
+
+// New
+//
+//	takes a *gopunch.Client and the GraphQL endpoint
+//	returns *graphql.Client
+func New(client *gopunch.Client, endPoint string) *Client {
+	return &Client{
+		client:   client,
+		endPoint: endPoint,
+	}
+}
+
+// SetPersistedQueries
+//
+//	enables automatic persisted queries, the query hash is sent first
+//	and the full query only when the server does not know the hash yet
+func (c *Client) SetPersistedQueries(enabled bool) {
+	c.persisted = enabled
+}
+
+// Query
+//
+//	takes context, query, variables, pointer to which data will be unmarshalled and option functions
+//	returns Errors when the response carries a GraphQL errors array, data is still decoded
+func (c *Client) Query(ctx context.Context, query string, variables map[string]interface{}, dest interface{}, opts ...gopunch.Option) error {
+	return c.do(ctx, query, variables, dest, opts)
+}
+
+// Mutate
+//
+//	same as Query, for mutations
+//	*Upload values inside variables are sent as multipart file uploads
+func (c *Client) Mutate(ctx context.Context, mutation string, variables map[string]interface{}, dest interface{}, opts ...gopunch.Option) error {
+	return c.do(ctx, mutation, variables, dest, opts)
+}
+
+func (c *Client) do(ctx context.Context, query string, variables map[string]interface{}, dest interface{}, opts []gopunch.Option) error {
+	req := &request{Query: query, Variables: variables}
+
+	if hasUploads(variables) {
+		payload, contentType, err := multipartPayload(req)
+		if err != nil {
+			return err
+		}
+
+		return c.send(ctx, payload, contentType, dest, opts)
+	}
+
+	if !c.persisted {
+		payload, err := json.Marshal(req)
+		if err != nil {
+			return err
+		}
+
+		return c.send(ctx, payload, "application/json", dest, opts)
+	}
+
+	sum := sha256.Sum256([]byte(query))
+	req.Extensions = map[string]interface{}{
+		"persistedQuery": map[string]interface{}{
+			"version":    1,
+			"sha256Hash": hex.EncodeToString(sum[:]),
+		},
+	}
+
+	// hash only first, the server answers PersistedQueryNotFound when it has to learn it
+	req.Query = ""
+	payload, err := json.Marshal(req)
+	if err != nil {
+		return err
+	}
+
+	err = c.send(ctx, payload, "application/json", dest, opts)
+	if !isPersistedQueryNotFound(err) {
+		return err
+	}
+
+	req.Query = query
+	payload, err = json.Marshal(req)
+	if err != nil {
+		return err
+	}
+
+	return c.send(ctx, payload, "application/json", dest, opts)
+}
+
+func (c *Client) send(ctx context.Context, payload []byte, contentType string, dest interface{}, opts []gopunch.Option) error {
+	opts = append(append([]gopunch.Option{}, opts...), func(req *http.Request) {
+		req.Header.Set("Content-Type", contentType)
+		req.Header.Set("Accept", "application/json")
+	})
+
+	resp := c.client.Post(ctx, c.endPoint, payload, opts...)
+	defer resp.Close()
+
+	if resp.Err() != nil {
+		return resp.Err()
+	}
+
+	httpResponse := resp.HttpResponse()
+	body, err := io.ReadAll(httpResponse.Body)
+	if err != nil {
+		return err
+	}
+
+	var result response
+	if err := json.Unmarshal(body, &result); err != nil {
+		if httpResponse.StatusCode < 200 || httpResponse.StatusCode > 299 {
+			return &gopunch.StatusError{StatusCode: httpResponse.StatusCode, Status: httpResponse.Status}
+		}
+
+		return err
+	}
+
+	if dest != nil && len(result.Data) > 0 && string(result.Data) != "null" {
+		if err := json.Unmarshal(result.Data, dest); err != nil {
+			return err
+		}
+	}
+
+	if len(result.Errors) > 0 {
+		return result.Errors
+	}
+
+	if httpResponse.StatusCode < 200 || httpResponse.StatusCode > 299 {
+		return &gopunch.StatusError{StatusCode: httpResponse.StatusCode, Status: httpResponse.Status}
+	}
+
+	return nil
+}
+
+func isPersistedQueryNotFound(err error) bool {
+	errs, ok := err.(Errors)
+	if !ok {
+		return false
+	}
+
+	for _, e := range errs {
+		if e.Message == "PersistedQueryNotFound" || e.Extensions["code"] == "PERSISTED_QUERY_NOT_FOUND" {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,138 @@
+package graphql
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/haquenafeem/gopunch"
+)
+
+func Test_Query(t *testing.T) {
+	t.Log("Given query with variables; data should be decoded into destination")
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req request
+		json.NewDecoder(r.Body).Decode(&req)
+		if r.Header.Get("Content-Type") != "application/json" || req.Variables["id"] != "1" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		w.Write([]byte(`{"data":{"todo":{"id":"1","title":"write tests"}}}`))
+	}))
+	defer server.Close()
+
+	client := New(gopunch.New(server.URL), "/graphql")
+
+	var result struct {
+		Todo struct {
+			ID    string `json:"id"`
+			Title string `json:"title"`
+		} `json:"todo"`
+	}
+
+	err := client.Query(context.Background(), `query($id: ID!) { todo(id: $id) { id title } }`, map[string]interface{}{"id": "1"}, &result)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if result.Todo.Title != "write tests" {
+		t.Fail()
+	}
+}
+
+func Test_Mutate_Errors(t *testing.T) {
+	t.Log("Given response with partial data and errors; data should be decoded and Errors returned with paths and extensions")
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"data":{"a":1,"b":null},"errors":[{"message":"forbidden","path":["b"],"locations":[{"line":1,"column":5}],"extensions":{"code":"FORBIDDEN"}}]}`))
+	}))
+	defer server.Close()
+
+	client := New(gopunch.New(server.URL), "/graphql")
+
+	var result struct {
+		A int `json:"a"`
+	}
+
+	err := client.Mutate(context.Background(), `mutation { a b }`, nil, &result)
+
+	var errs Errors
+	if !errors.As(err, &errs) || len(errs) != 1 {
+		t.Fatal(err)
+	}
+
+	single := errs[0]
+	if single.Extensions["code"] != "FORBIDDEN" || single.Path[0] != "b" || single.Locations[0].Column != 5 {
+		t.Fail()
+	}
+
+	if result.A != 1 {
+		t.Fail()
+	}
+}
+
+func Test_PersistedQueries(t *testing.T) {
+	t.Log("Given APQ enabled; unknown hash should fall back to sending the query once, later calls send only the hash")
+	var mu sync.Mutex
+	known := map[string]string{}
+	var withQuery, hashOnly int
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req request
+		json.NewDecoder(r.Body).Decode(&req)
+		hash := req.Extensions["persistedQuery"].(map[string]interface{})["sha256Hash"].(string)
+
+		mu.Lock()
+		defer mu.Unlock()
+
+		if req.Query == "" {
+			hashOnly++
+			if _, ok := known[hash]; !ok {
+				w.Write([]byte(`{"errors":[{"message":"PersistedQueryNotFound","extensions":{"code":"PERSISTED_QUERY_NOT_FOUND"}}]}`))
+				return
+			}
+		} else {
+			withQuery++
+			known[hash] = req.Query
+		}
+
+		w.Write([]byte(`{"data":{"ok":true}}`))
+	}))
+	defer server.Close()
+
+	client := New(gopunch.New(server.URL), "/graphql")
+	client.SetPersistedQueries(true)
+
+	for i := 0; i < 2; i++ {
+		var result struct {
+			OK bool `json:"ok"`
+		}
+
+		if err := client.Query(context.Background(), `{ ok }`, nil, &result); err != nil || !result.OK {
+			t.Fatal(err)
+		}
+	}
+
+	if withQuery != 1 || hashOnly != 2 {
+		t.Logf("expected 1 full and 2 hash only requests, got %d and %d", withQuery, hashOnly)
+		t.Fail()
+	}
+}
+
+func Test_Query_StatusError(t *testing.T) {
+	t.Log("Given non GraphQL error response; StatusError should be returned")
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadGateway)
+		w.Write([]byte("bad gateway"))
+	}))
+	defer server.Close()
+
+	var statusErr *gopunch.StatusError
+	err := New(gopunch.New(server.URL), "/graphql").Query(context.Background(), `{ ok }`, nil, nil)
+	if !errors.As(err, &statusErr) || statusErr.StatusCode != http.StatusBadGateway {
+		t.Fail()
+	}
+}
@@ -0,0 +1,147 @@
+package graphql
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"mime/multipart"
+	"net/textproto"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Upload
+//
+//	file sent with the GraphQL multipart request spec, pass *Upload as a variable value
+//	or inside slices and maps such as []*Upload
+type Upload struct {
+	Filename    string
+	ContentType string
+	Reader      io.Reader
+}
+
+// hasUploads looks for *Upload values, walking slices, arrays and maps of any element type such as []*Upload
+func hasUploads(value interface{}) bool {
+	if _, ok := value.(*Upload); ok {
+		return true
+	}
+
+	v := reflect.ValueOf(value)
+	switch v.Kind() {
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < v.Len(); i++ {
+			if hasUploads(v.Index(i).Interface()) {
+				return true
+			}
+		}
+	case reflect.Map:
+		if v.Type().Key().Kind() != reflect.String {
+			return false
+		}
+
+		iter := v.MapRange()
+		for iter.Next() {
+			if hasUploads(iter.Value().Interface()) {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// extractUploads replaces every *Upload with null and records its object path
+// containers holding uploads are copied into []interface{} and map[string]interface{}, others are kept as they are
+func extractUploads(value interface{}, path string, uploads map[string]*Upload) interface{} {
+	if upload, ok := value.(*Upload); ok {
+		uploads[path] = upload
+		return nil
+	}
+
+	if !hasUploads(value) {
+		return value
+	}
+
+	v := reflect.ValueOf(value)
+	if v.Kind() == reflect.Map {
+		copied := make(map[string]interface{}, v.Len())
+		iter := v.MapRange()
+		for iter.Next() {
+			key := iter.Key().String()
+			copied[key] = extractUploads(iter.Value().Interface(), path+"."+key, uploads)
+		}
+		return copied
+	}
+
+	copied := make([]interface{}, v.Len())
+	for i := range copied {
+		copied[i] = extractUploads(v.Index(i).Interface(), path+"."+strconv.Itoa(i), uploads)
+	}
+	return copied
+}
+
+func multipartPayload(req *request) ([]byte, string, error) {
+	uploads := map[string]*Upload{}
+	variables := extractUploads(req.Variables, "variables", uploads).(map[string]interface{})
+
+	operations, err := json.Marshal(&request{Query: req.Query, OperationName: req.OperationName, Variables: variables})
+	if err != nil {
+		return nil, "", err
+	}
+
+	paths := make([]string, 0, len(uploads))
+	for path := range uploads {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+
+	fileMap := map[string][]string{}
+	for i, path := range paths {
+		fileMap[strconv.Itoa(i)] = []string{path}
+	}
+
+	mapJSON, err := json.Marshal(fileMap)
+	if err != nil {
+		return nil, "", err
+	}
+
+	body := &bytes.Buffer{}
+	writer := multipart.NewWriter(body)
+	if err := writer.WriteField("operations", string(operations)); err != nil {
+		return nil, "", err
+	}
+
+	if err := writer.WriteField("map", string(mapJSON)); err != nil {
+		return nil, "", err
+	}
+
+	escape := strings.NewReplacer("\\", "\\\\", `"`, "\\\"")
+	for i, path := range paths {
+		upload := uploads[path]
+		contentType := upload.ContentType
+		if contentType == "" {
+			contentType = "application/octet-stream"
+		}
+
+		header := textproto.MIMEHeader{}
+		header.Set("Content-Disposition", `form-data; name="`+strconv.Itoa(i)+`"; filename="`+escape.Replace(upload.Filename)+`"`)
+		header.Set("Content-Type", contentType)
+
+		part, err := writer.CreatePart(header)
+		if err != nil {
+			return nil, "", err
+		}
+
+		if _, err := io.Copy(part, upload.Reader); err != nil {
+			return nil, "", err
+		}
+	}
+
+	if err := writer.Close(); err != nil {
+		return nil, "", err
+	}
+
+	return body.Bytes(), writer.FormDataContentType(), nil
+}
@@ -0,0 +1,122 @@
+package graphql
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/haquenafeem/gopunch"
+)
+
+func Test_Mutate_Upload(t *testing.T) {
+	t.Log("Given variables holding uploads; request should follow the multipart request spec")
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := r.ParseMultipartForm(1 << 20); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		var operations request
+		json.Unmarshal([]byte(r.FormValue("operations")), &operations)
+		files := operations.Variables["files"].([]interface{})
+		if operations.Variables["file"] != nil || files[0] != nil || files[1] != "keep" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		var fileMap map[string][]string
+		json.Unmarshal([]byte(r.FormValue("map")), &fileMap)
+
+		contents := map[string]string{}
+		for name, paths := range fileMap {
+			file, header, err := r.FormFile(name)
+			if err != nil {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			data, _ := io.ReadAll(file)
+			contents[paths[0]] = header.Filename + ":" + string(data)
+		}
+
+		json.NewEncoder(w).Encode(map[string]interface{}{"data": contents})
+	}))
+	defer server.Close()
+
+	client := New(gopunch.New(server.URL), "/graphql")
+	variables := map[string]interface{}{
+		"file": &Upload{Filename: "a.txt", Reader: strings.NewReader("first")},
+		"files": []interface{}{
+			&Upload{Filename: "b.txt", ContentType: "text/plain", Reader: strings.NewReader("second")},
+			"keep",
+		},
+	}
+
+	var result map[string]string
+	if err := client.Mutate(context.Background(), `mutation($file: Upload!, $files: [Upload!]!) { upload }`, variables, &result); err != nil {
+		t.Fatal(err)
+	}
+
+	if result["variables.file"] != "a.txt:first" || result["variables.files.0"] != "b.txt:second" {
+		t.Logf("unexpected result %v", result)
+		t.Fail()
+	}
+}
+
+func Test_Mutate_UploadTypedContainers(t *testing.T) {
+	t.Log("Given uploads in []*Upload and map[string]*Upload; each should be sent as a file")
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := r.ParseMultipartForm(1 << 20); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		var operations request
+		json.Unmarshal([]byte(r.FormValue("operations")), &operations)
+		files, _ := operations.Variables["files"].([]interface{})
+		named, _ := operations.Variables["named"].(map[string]interface{})
+		if len(files) != 2 || files[0] != nil || files[1] != nil || len(named) != 1 || named["avatar"] != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		var fileMap map[string][]string
+		json.Unmarshal([]byte(r.FormValue("map")), &fileMap)
+
+		contents := map[string]string{}
+		for name, paths := range fileMap {
+			file, _, err := r.FormFile(name)
+			if err != nil {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			data, _ := io.ReadAll(file)
+			contents[paths[0]] = string(data)
+		}
+
+		json.NewEncoder(w).Encode(map[string]interface{}{"data": contents})
+	}))
+	defer server.Close()
+
+	client := New(gopunch.New(server.URL), "/graphql")
+	variables := map[string]interface{}{
+		"files": []*Upload{
+			{Filename: "a.txt", Reader: strings.NewReader("first")},
+			{Filename: "b.txt", Reader: strings.NewReader("second")},
+		},
+		"named": map[string]*Upload{"avatar": {Filename: "c.png", Reader: strings.NewReader("third")}},
+		"raw":   []byte("kept"),
+	}
+
+	var result map[string]string
+	if err := client.Mutate(context.Background(), `mutation($files: [Upload!]!, $named: Named!, $raw: String!) { upload }`, variables, &result); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(result) != 3 || result["variables.files.0"] != "first" || result["variables.files.1"] != "second" || result["variables.named.avatar"] != "third" {
+		t.Fatal(result)
+	}
+}
@@ -0,0 +1,272 @@
+// Package jsonrpc makes JSON-RPC 2.0 calls over HTTP through a *gopunch.Client.
+package jsonrpc
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"sync/atomic"
+
+	"github.com/haquenafeem/gopunch"
+)
+
+const version = "2.0"
+
+var (
+	ErrMissingResponse = errors.New("jsonrpc: no response for call")
+	ErrInvalidResponse = errors.New("jsonrpc: response has neither result nor error")
+	ErrMismatchedID    = errors.New("jsonrpc: response id does not match the call")
+)
+
+// Error
+//
+//	error object returned by the server
+type Error struct {
+	Code    int             `json:"code"`
+	Message string          `json:"message"`
+	Data    json.RawMessage `json:"data,omitempty"`
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("jsonrpc: %d %s", e.Code, e.Message)
+}
+
+type request struct {
+	JSONRPC string      `json:"jsonrpc"`
+	Method  string      `json:"method"`
+	Params  interface{} `json:"params,omitempty"`
+	ID      *uint64     `json:"id,omitempty"`
+}
+
+type response struct {
+	JSONRPC string          `json:"jsonrpc"`
+	Result  json.RawMessage `json:"result"`
+	Error   *Error          `json:"error"`
+	ID      json.RawMessage `json:"id"`
+}
+
+// BatchCall
+//
+//	single entry of a batch, Result and Err are filled once the batch is sent
+//	notifications get no response so their Result and Err stay untouched
+type BatchCall struct {
+	Method       string
+	Params       interface{}
+	Result       interface{}
+	Notification bool
+	Err          error
+}
+
+// Client
+//
+//	sends JSON-RPC 2.0 requests to a single endpoint
+type Client struct {
+	client   *gopunch.Client
+	endPoint string
+	nextID   atomic.Uint64
+}
+
+// New
+//
+//	takes a *gopunch.Client and the JSON-RPC endpoint
+//	returns *jsonrpc.Client
+func New(client *gopunch.Client, endPoint string) *Client {
+	return &Client{
+		client:   client,
+		endPoint: endPoint,
+	}
+}
+
+func (c *Client) newID() *uint64 {
+	id := c.nextID.Add(1)
+	return &id
+}
+
+// Call
+//
+//	takes context, method, params (array or object), pointer to which the result will be unmarshalled and option functions
+//	returns *Error when the server answered with an error object
+//	returns ErrMismatchedID or ErrInvalidResponse when the response is not an answer to the call
+func (c *Client) Call(ctx context.Context, method string, params interface{}, result interface{}, opts ...gopunch.Option) error {
+	id := c.newID()
+	payload, err := json.Marshal(&request{JSONRPC: version, Method: method, Params: params, ID: id})
+	if err != nil {
+		return err
+	}
+
+	body, err := c.post(ctx, payload, opts)
+	if err != nil {
+		return err
+	}
+
+	if len(body) == 0 {
+		return ErrMissingResponse
+	}
+
+	var resp response
+	if err := json.Unmarshal(body, &resp); err != nil {
+		return err
+	}
+
+	// an error about the request itself, such as a parse error, comes back with a null id
+	if resp.Error == nil && resp.id() != strconv.FormatUint(*id, 10) {
+		return ErrMismatchedID
+	}
+
+	return resp.decode(result)
+}
+
+// Notify
+//
+//	takes context, method, params and option functions
+//	sends a notification, the server sends no response back
+func (c *Client) Notify(ctx context.Context, method string, params interface{}, opts ...gopunch.Option) error {
+	payload, err := json.Marshal(&request{JSONRPC: version, Method: method, Params: params})
+	if err != nil {
+		return err
+	}
+
+	_, err = c.post(ctx, payload, opts)
+
+	return err
+}
+
+// CallBatch
+//
+//	sends every call in a single batch request
+//	responses are matched to calls by id, whatever order they arrive in
+//	returns an error only when the batch as a whole failed
+func (c *Client) CallBatch(ctx context.Context, calls []*BatchCall, opts ...gopunch.Option) error {
+	requests := make([]*request, len(calls))
+	byID := map[string]*BatchCall{}
+	for i, call := range calls {
+		requests[i] = &request{JSONRPC: version, Method: call.Method, Params: call.Params}
+		if !call.Notification {
+			requests[i].ID = c.newID()
+			byID[strconv.FormatUint(*requests[i].ID, 10)] = call
+		}
+	}
+
+	payload, err := json.Marshal(requests)
+	if err != nil {
+		return err
+	}
+
+	body, err := c.post(ctx, payload, opts)
+	if err != nil {
+		return err
+	}
+
+	body = bytes.TrimSpace(body)
+	if len(body) > 0 && body[0] == '{' {
+		// a single response means the server rejected the batch itself
+		var resp response
+		if err := json.Unmarshal(body, &resp); err != nil {
+			return err
+		}
+
+		if resp.Error != nil {
+			return resp.Error
+		}
+
+		return fmt.Errorf("jsonrpc: unexpected single response to batch")
+	}
+
+	var responses []response
+	if len(body) > 0 {
+		if err := json.Unmarshal(body, &responses); err != nil {
+			return err
+		}
+	}
+
+	for i := range responses {
+		call, ok := byID[responses[i].id()]
+		if !ok {
+			continue
+		}
+
+		call.Err = responses[i].decode(call.Result)
+		delete(byID, responses[i].id())
+	}
+
+	for _, call := range byID {
+		call.Err = ErrMissingResponse
+	}
+
+	return nil
+}
+
+// id returns the response id without the quotes of a string id
+func (r *response) id() string {
+	return string(bytes.Trim(r.ID, `"`))
+}
+
+func (r *response) decode(result interface{}) error {
+	if r.Error != nil {
+		return r.Error
+	}
+
+	// a null result is still a result, a missing one is not
+	if len(r.Result) == 0 {
+		return ErrInvalidResponse
+	}
+
+	if result == nil {
+		return nil
+	}
+
+	return json.Unmarshal(r.Result, result)
+}
+
+func (c *Client) post(ctx context.Context, payload []byte, opts []gopunch.Option) ([]byte, error) {
+	opts = append(append([]gopunch.Option{}, opts...), func(req *http.Request) {
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Accept", "application/json")
+	})
+
+	resp := c.client.Post(ctx, c.endPoint, payload, opts...)
+	defer resp.Close()
+
+	if resp.Err() != nil {
+		return nil, resp.Err()
+	}
+
+	httpResponse := resp.HttpResponse()
+	body, err := io.ReadAll(httpResponse.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	// servers may pair an error object with a 4xx or 5xx status, any other body is not an answer
+	if (httpResponse.StatusCode < 200 || httpResponse.StatusCode > 299) && !hasErrorObject(body) {
+		return nil, &gopunch.StatusError{StatusCode: httpResponse.StatusCode, Status: httpResponse.Status}
+	}
+
+	return body, nil
+}
+
+// hasErrorObject reports whether body is a response, or a batch of responses, carrying a JSON-RPC error
+func hasErrorObject(body []byte) bool {
+	var resp response
+	if json.Unmarshal(body, &resp) == nil {
+		return resp.Error != nil
+	}
+
+	var responses []response
+	if json.Unmarshal(body, &responses) != nil {
+		return false
+	}
+
+	for i := range responses {
+		if responses[i].Error != nil {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,215 @@
+package jsonrpc
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/haquenafeem/gopunch"
+)
+
+type serverRequest struct {
+	Method string          `json:"method"`
+	Params []float64       `json:"params"`
+	ID     json.RawMessage `json:"id"`
+}
+
+func handle(req serverRequest) map[string]interface{} {
+	resp := map[string]interface{}{"jsonrpc": "2.0", "id": req.ID}
+	switch req.Method {
+	case "add":
+		resp["result"] = req.Params[0] + req.Params[1]
+	default:
+		resp["error"] = map[string]interface{}{"code": -32601, "message": "Method not found", "data": req.Method}
+	}
+
+	return resp
+}
+
+// rpcServer answers single and batch requests, batch responses are sent in reverse order
+func rpcServer(notified *[]string) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var raw json.RawMessage
+		json.NewDecoder(r.Body).Decode(&raw)
+
+		if raw[0] == '[' {
+			var batch []serverRequest
+			json.Unmarshal(raw, &batch)
+
+			var responses []interface{}
+			for i := len(batch) - 1; i >= 0; i-- {
+				if batch[i].ID == nil {
+					*notified = append(*notified, batch[i].Method)
+					continue
+				}
+				responses = append(responses, handle(batch[i]))
+			}
+			json.NewEncoder(w).Encode(responses)
+			return
+		}
+
+		var req serverRequest
+		json.Unmarshal(raw, &req)
+		if req.ID == nil {
+			*notified = append(*notified, req.Method)
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+
+		json.NewEncoder(w).Encode(handle(req))
+	}))
+}
+
+func Test_Call(t *testing.T) {
+	var notified []string
+	server := rpcServer(&notified)
+	defer server.Close()
+
+	client := New(gopunch.New(server.URL), "/rpc")
+	ctx := context.Background()
+
+	t.Log("Given add call; result should be decoded")
+	var sum float64
+	if err := client.Call(ctx, "add", []int{2, 3}, &sum); err != nil || sum != 5 {
+		t.Fatal(err)
+	}
+
+	t.Log("Given unknown method; *Error should carry code, message and data")
+	var rpcErr *Error
+	err := client.Call(ctx, "missing", []int{}, nil)
+	if !errors.As(err, &rpcErr) || rpcErr.Code != -32601 || string(rpcErr.Data) != `"missing"` {
+		t.Fail()
+	}
+
+	t.Log("Given notification; no response should be expected")
+	if err := client.Notify(ctx, "log", []int{1}); err != nil || len(notified) != 1 {
+		t.Fail()
+	}
+}
+
+func Test_CallBatch(t *testing.T) {
+	t.Log("Given batch answered out of order; each call should receive its own result or error")
+	var notified []string
+	server := rpcServer(&notified)
+	defer server.Close()
+
+	client := New(gopunch.New(server.URL), "/rpc")
+
+	var first, second float64
+	calls := []*BatchCall{
+		{Method: "add", Params: []int{1, 1}, Result: &first},
+		{Method: "log", Params: []int{0}, Notification: true},
+		{Method: "nope", Params: []int{}},
+		{Method: "add", Params: []int{10, 20}, Result: &second},
+	}
+
+	if err := client.CallBatch(context.Background(), calls); err != nil {
+		t.Fatal(err)
+	}
+
+	if first != 2 || second != 30 || calls[0].Err != nil || calls[3].Err != nil {
+		t.Fail()
+	}
+
+	var rpcErr *Error
+	if !errors.As(calls[2].Err, &rpcErr) || rpcErr.Code != -32601 {
+		t.Fail()
+	}
+
+	if calls[1].Err != nil || len(notified) != 1 {
+		t.Fail()
+	}
+}
+
+func Test_CallBatch_MissingResponse(t *testing.T) {
+	t.Log("Given server dropping a response; that call should get ErrMissingResponse")
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var batch []serverRequest
+		json.NewDecoder(r.Body).Decode(&batch)
+		json.NewEncoder(w).Encode([]interface{}{handle(batch[0])})
+	}))
+	defer server.Close()
+
+	var sum float64
+	calls := []*BatchCall{
+		{Method: "add", Params: []int{1, 2}, Result: &sum},
+		{Method: "add", Params: []int{3, 4}},
+	}
+
+	if err := New(gopunch.New(server.URL), "/rpc").CallBatch(context.Background(), calls); err != nil {
+		t.Fatal(err)
+	}
+
+	if sum != 3 || !errors.Is(calls[1].Err, ErrMissingResponse) {
+		t.Fail()
+	}
+}
+
+func Test_Call_InvalidResponses(t *testing.T) {
+	testCases := []struct {
+		Title    string
+		Status   int
+		Body     func(id json.RawMessage) string
+		Expected func(err error) bool
+	}{
+		{
+			Title:  "Given 500 with a JSON body that is not a JSON-RPC error; it should fail with *gopunch.StatusError",
+			Status: http.StatusInternalServerError,
+			Body:   func(id json.RawMessage) string { return `{"message":"boom"}` },
+			Expected: func(err error) bool {
+				var statusErr *gopunch.StatusError
+				return errors.As(err, &statusErr) && statusErr.StatusCode == http.StatusInternalServerError
+			},
+		},
+		{
+			Title:  "Given 500 carrying an error object; it should fail with *Error",
+			Status: http.StatusInternalServerError,
+			Body: func(id json.RawMessage) string {
+				return `{"jsonrpc":"2.0","id":` + string(id) + `,"error":{"code":-32603,"message":"Internal error"}}`
+			},
+			Expected: func(err error) bool {
+				var rpcErr *Error
+				return errors.As(err, &rpcErr) && rpcErr.Code == -32603
+			},
+		},
+		{
+			Title:    "Given response with neither result nor error; it should fail with ErrInvalidResponse",
+			Status:   http.StatusOK,
+			Body:     func(id json.RawMessage) string { return `{"jsonrpc":"2.0","id":` + string(id) + `}` },
+			Expected: func(err error) bool { return errors.Is(err, ErrInvalidResponse) },
+		},
+		{
+			Title:    "Given response for another id; it should fail with ErrMismatchedID",
+			Status:   http.StatusOK,
+			Body:     func(id json.RawMessage) string { return `{"jsonrpc":"2.0","id":999,"result":1}` },
+			Expected: func(err error) bool { return errors.Is(err, ErrMismatchedID) },
+		},
+		{
+			Title:    "Given null result; it should succeed",
+			Status:   http.StatusOK,
+			Body:     func(id json.RawMessage) string { return `{"jsonrpc":"2.0","id":` + string(id) + `,"result":null}` },
+			Expected: func(err error) bool { return err == nil },
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Log(testCase.Title)
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			var req serverRequest
+			json.NewDecoder(r.Body).Decode(&req)
+			w.WriteHeader(testCase.Status)
+			w.Write([]byte(testCase.Body(req.ID)))
+		}))
+
+		var result interface{}
+		err := New(gopunch.New(server.URL), "/rpc").Call(context.Background(), "anything", nil, &result)
+		server.Close()
+
+		if !testCase.Expected(err) {
+			t.Fatal(err)
+		}
+	}
+}
@@ -0,0 +1,464 @@
+// Package jwt mints and validates JSON Web Tokens, for use with gopunch.TokenAuth.
+package jwt
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+	"time"
+
+	"github.com/haquenafeem/gopunch"
+)
+
+// Algorithm
+//
+//	JWS signing algorithm
+type Algorithm string
+
+const (
+	// HS256 signs with HMAC-SHA256, the key is a []byte
+	HS256 Algorithm = "HS256"
+	// RS256 signs with RSASSA-PKCS1-v1_5 SHA-256, the key is a *rsa.PrivateKey or *rsa.PublicKey
+	RS256 Algorithm = "RS256"
+	// ES256 signs with ECDSA P-256 SHA-256, the key is a *ecdsa.PrivateKey or *ecdsa.PublicKey
+	ES256 Algorithm = "ES256"
+)
+
+// DefaultTTL
+//
+//	lifetime of minted tokens when Config.TTL is zero
+const DefaultTTL = 5 * time.Minute
+
+var ErrMalformed = errors.New("jwt: malformed token")
+var ErrInvalidSignature = errors.New("jwt: invalid signature")
+var ErrUnsupportedAlgorithm = errors.New("jwt: unsupported algorithm or key type")
+var ErrExpired = errors.New("jwt: token is expired")
+var ErrNotValidYet = errors.New("jwt: token is not valid yet")
+var ErrInvalidClaim = errors.New("jwt: invalid claim")
+
+// nowFunc is replaced in tests to control the clock
+var nowFunc = time.Now
+
+// Claims
+//
+//	JWT claims set
+type Claims map[string]interface{}
+
+// String
+//
+//	returns a string claim, empty when missing
+func (c Claims) String(name string) string {
+	value, _ := c[name].(string)
+	return value
+}
+
+// Time
+//
+//	returns a NumericDate claim such as "exp", zero when missing
+func (c Claims) Time(name string) time.Time {
+	switch value := c[name].(type) {
+	case float64:
+		return time.Unix(int64(value), 0)
+	case int64:
+		return time.Unix(value, 0)
+	case json.Number:
+		if seconds, err := value.Int64(); err == nil {
+			return time.Unix(seconds, 0)
+		}
+	}
+
+	return time.Time{}
+}
+
+// Audience
+//
+//	returns the "aud" claim, which may be a single string or a list
+func (c Claims) Audience() []string {
+	switch value := c["aud"].(type) {
+	case string:
+		return []string{value}
+	case []string:
+		return value
+	case []interface{}:
+		audience := make([]string, 0, len(value))
+		for _, item := range value {
+			if s, ok := item.(string); ok {
+				audience = append(audience, s)
+			}
+		}
+		return audience
+	}
+
+	return nil
+}
+
+// Config
+//
+//	describes the tokens to mint
+type Config struct {
+	Algorithm Algorithm
+	// Key is a []byte for HS256, *rsa.PrivateKey for RS256 and *ecdsa.PrivateKey for ES256
+	Key interface{}
+	// KeyID is sent as the "kid" header when not empty
+	KeyID    string
+	Issuer   string
+	Subject  string
+	Audience []string
+	// TTL is the lifetime of each token, DefaultTTL when zero
+	TTL time.Duration
+	// Claims are added to every token, Mint always sets "iat" and "exp" and sets "jti" unless present
+	Claims Claims
+}
+
+// Mint
+//
+//	returns a newly signed token and its expiry
+func (cfg Config) Mint() (string, time.Time, error) {
+	ttl := cfg.TTL
+	if ttl == 0 {
+		ttl = DefaultTTL
+	}
+
+	now := nowFunc()
+	expiry := now.Add(ttl)
+
+	claims := Claims{}
+	for name, value := range cfg.Claims {
+		claims[name] = value
+	}
+
+	if cfg.Issuer != "" {
+		claims["iss"] = cfg.Issuer
+	}
+
+	if cfg.Subject != "" {
+		claims["sub"] = cfg.Subject
+	}
+
+	switch len(cfg.Audience) {
+	case 0:
+	case 1:
+		claims["aud"] = cfg.Audience[0]
+	default:
+		claims["aud"] = cfg.Audience
+	}
+
+	claims["iat"] = now.Unix()
+	claims["exp"] = expiry.Unix()
+	if _, ok := claims["jti"]; !ok {
+		var id [16]byte
+		if _, err := rand.Read(id[:]); err != nil {
+			return "", time.Time{}, err
+		}
+		claims["jti"] = hex.EncodeToString(id[:])
+	}
+
+	header := map[string]string{"alg": string(cfg.Algorithm), "typ": "JWT"}
+	if cfg.KeyID != "" {
+		header["kid"] = cfg.KeyID
+	}
+
+	token, err := Sign(header, claims, cfg.Key)
+	if err != nil {
+		return "", time.Time{}, err
+	}
+
+	return token, expiry, nil
+}
+
+// Source
+//
+//	takes Config
+//	returns gopunch.TokenSource minting a new token on every call
+func Source(cfg Config) gopunch.TokenSource {
+	return gopunch.TokenSourceFunc(func(ctx context.Context) (*gopunch.Token, error) {
+		token, expiry, err := cfg.Mint()
+		if err != nil {
+			return nil, err
+		}
+
+		return &gopunch.Token{AccessToken: token, TokenType: "Bearer", Expiry: expiry}, nil
+	})
+}
+
+// Auth
+//
+//	takes Config
+//	returns *gopunch.TokenAuth reusing each token until shortly before it expires
+func Auth(cfg Config) *gopunch.TokenAuth {
+	return gopunch.NewTokenAuth(Source(cfg))
+}
+
+// Sign
+//
+//	takes the JOSE header, which must hold "alg", the claims and the signing key
+//	returns the compact serialized token
+func Sign(header map[string]string, claims Claims, key interface{}) (string, error) {
+	headerJSON, err := json.Marshal(header)
+	if err != nil {
+		return "", err
+	}
+
+	claimsJSON, err := json.Marshal(claims)
+	if err != nil {
+		return "", err
+	}
+
+	signingInput := encodeSegment(headerJSON) + "." + encodeSegment(claimsJSON)
+	signature, err := sign(Algorithm(header["alg"]), key, signingInput)
+	if err != nil {
+		return "", err
+	}
+
+	return signingInput + "." + encodeSegment(signature), nil
+}
+
+// Token
+//
+//	a decoded token
+type Token struct {
+	Header map[string]interface{}
+	Claims Claims
+}
+
+type validator struct {
+	issuer   string
+	audience string
+	leeway   time.Duration
+}
+
+// ValidateOption
+//
+//	adds checks to Parse
+type ValidateOption func(v *validator)
+
+// WithIssuer
+//
+//	requires the "iss" claim to equal issuer
+func WithIssuer(issuer string) ValidateOption {
+	return func(v *validator) {
+		v.issuer = issuer
+	}
+}
+
+// WithAudience
+//
+//	requires the "aud" claim to contain audience
+func WithAudience(audience string) ValidateOption {
+	return func(v *validator) {
+		v.audience = audience
+	}
+}
+
+// WithLeeway
+//
+//	takes the clock skew tolerated when checking "exp" and "nbf"
+func WithLeeway(leeway time.Duration) ValidateOption {
+	return func(v *validator) {
+		v.leeway = leeway
+	}
+}
+
+// Parse
+//
+//	takes a token, the verification key and validate options
+//	the key type must match the "alg" header, so an RSA or EC public key is never used as an HMAC secret
+//	checks the signature, "exp" and "nbf"
+//	returns *Token
+func Parse(token string, key interface{}, opts ...ValidateOption) (*Token, error) {
+	parsed, signingInput, signature, err := decode(token)
+	if err != nil {
+		return nil, err
+	}
+
+	alg, _ := parsed.Header["alg"].(string)
+	if err := verify(Algorithm(alg), key, signingInput, signature); err != nil {
+		return nil, err
+	}
+
+	v := &validator{}
+	for _, opt := range opts {
+		opt(v)
+	}
+
+	now := nowFunc()
+	if expiry := parsed.Claims.Time("exp"); !expiry.IsZero() && !now.Before(expiry.Add(v.leeway)) {
+		return nil, ErrExpired
+	}
+
+	if notBefore := parsed.Claims.Time("nbf"); !notBefore.IsZero() && now.Add(v.leeway).Before(notBefore) {
+		return nil, ErrNotValidYet
+	}
+
+	if v.issuer != "" && parsed.Claims.String("iss") != v.issuer {
+		return nil, fmt.Errorf("%w: iss", ErrInvalidClaim)
+	}
+
+	if v.audience != "" && !contains(parsed.Claims.Audience(), v.audience) {
+		return nil, fmt.Errorf("%w: aud", ErrInvalidClaim)
+	}
+
+	return parsed, nil
+}
+
+// ParseUnverified
+//
+//	decodes a token without checking its signature or claims, never trust the result
+func ParseUnverified(token string) (*Token, error) {
+	parsed, _, _, err := decode(token)
+	return parsed, err
+}
+
+func decode(token string) (*Token, string, []byte, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, "", nil, ErrMalformed
+	}
+
+	parsed := &Token{}
+	if err := decodeJSONSegment(parts[0], &parsed.Header); err != nil {
+		return nil, "", nil, err
+	}
+
+	if err := decodeJSONSegment(parts[1], &parsed.Claims); err != nil {
+		return nil, "", nil, err
+	}
+
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, "", nil, ErrMalformed
+	}
+
+	return parsed, parts[0] + "." + parts[1], signature, nil
+}
+
+func decodeJSONSegment(segment string, dest interface{}) error {
+	data, err := base64.RawURLEncoding.DecodeString(segment)
+	if err != nil {
+		return ErrMalformed
+	}
+
+	if err := json.Unmarshal(data, dest); err != nil {
+		return fmt.Errorf("%w: %v", ErrMalformed, err)
+	}
+
+	return nil
+}
+
+func encodeSegment(data []byte) string {
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func sign(alg Algorithm, key interface{}, signingInput string) ([]byte, error) {
+	digest := sha256.Sum256([]byte(signingInput))
+
+	switch alg {
+	case HS256:
+		if secret, ok := key.([]byte); ok {
+			mac := hmac.New(sha256.New, secret)
+			mac.Write([]byte(signingInput))
+			return mac.Sum(nil), nil
+		}
+	case RS256:
+		if privateKey, ok := key.(*rsa.PrivateKey); ok {
+			return rsa.SignPKCS1v15(rand.Reader, privateKey, crypto.SHA256, digest[:])
+		}
+	case ES256:
+		if privateKey, ok := key.(*ecdsa.PrivateKey); ok && privateKey.Curve == elliptic.P256() {
+			r, s, err := ecdsa.Sign(rand.Reader, privateKey, digest[:])
+			if err != nil {
+				return nil, err
+			}
+
+			// JWS uses the fixed size R || S encoding instead of ASN.1
+			signature := make([]byte, 64)
+			r.FillBytes(signature[:32])
+			s.FillBytes(signature[32:])
+			return signature, nil
+		}
+	}
+
+	return nil, ErrUnsupportedAlgorithm
+}
+
+func verify(alg Algorithm, key interface{}, signingInput string, signature []byte) error {
+	digest := sha256.Sum256([]byte(signingInput))
+
+	switch alg {
+	case HS256:
+		if secret, ok := key.([]byte); ok {
+			mac := hmac.New(sha256.New, secret)
+			mac.Write([]byte(signingInput))
+			if !hmac.Equal(mac.Sum(nil), signature) {
+				return ErrInvalidSignature
+			}
+			return nil
+		}
+	case RS256:
+		if publicKey := rsaPublicKey(key); publicKey != nil {
+			if rsa.VerifyPKCS1v15(publicKey, crypto.SHA256, digest[:], signature) != nil {
+				return ErrInvalidSignature
+			}
+			return nil
+		}
+	case ES256:
+		if publicKey := ecdsaPublicKey(key); publicKey != nil && publicKey.Curve == elliptic.P256() {
+			if len(signature) != 64 {
+				return ErrInvalidSignature
+			}
+
+			r := new(big.Int).SetBytes(signature[:32])
+			s := new(big.Int).SetBytes(signature[32:])
+			if !ecdsa.Verify(publicKey, digest[:], r, s) {
+				return ErrInvalidSignature
+			}
+			return nil
+		}
+	}
+
+	return ErrUnsupportedAlgorithm
+}
+
+func rsaPublicKey(key interface{}) *rsa.PublicKey {
+	switch k := key.(type) {
+	case *rsa.PublicKey:
+		return k
+	case *rsa.PrivateKey:
+		return &k.PublicKey
+	}
+
+	return nil
+}
+
+func ecdsaPublicKey(key interface{}) *ecdsa.PublicKey {
+	switch k := key.(type) {
+	case *ecdsa.PublicKey:
+		return k
+	case *ecdsa.PrivateKey:
+		return &k.PublicKey
+	}
+
+	return nil
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+
+	return false
+}
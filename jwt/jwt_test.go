@@ -0,0 +1,180 @@
+package jwt
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/haquenafeem/gopunch"
+)
+
+func withFrozenClock(t *testing.T, at time.Time) *time.Time {
+	current := at
+	previous := nowFunc
+	nowFunc = func() time.Time { return current }
+	t.Cleanup(func() { nowFunc = previous })
+
+	return &current
+}
+
+func Test_SignParse(t *testing.T) {
+	rsaKey, _ := rsa.GenerateKey(rand.Reader, 2048)
+	ecKey, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+
+	testCases := []struct {
+		Title      string
+		Algorithm  Algorithm
+		SigningKey interface{}
+		VerifyKey  interface{}
+	}{
+		{Title: "Given HS256; token should round trip", Algorithm: HS256, SigningKey: []byte("secret"), VerifyKey: []byte("secret")},
+		{Title: "Given RS256; token should round trip", Algorithm: RS256, SigningKey: rsaKey, VerifyKey: &rsaKey.PublicKey},
+		{Title: "Given ES256; token should round trip", Algorithm: ES256, SigningKey: ecKey, VerifyKey: &ecKey.PublicKey},
+	}
+
+	for _, testCase := range testCases {
+		t.Log(testCase.Title)
+		token, _, err := Config{
+			Algorithm: testCase.Algorithm,
+			Key:       testCase.SigningKey,
+			KeyID:     "k1",
+			Issuer:    "gopunch",
+			Subject:   "svc",
+			Audience:  []string{"api", "admin"},
+			Claims:    Claims{"scope": "read"},
+		}.Mint()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		parsed, err := Parse(token, testCase.VerifyKey, WithIssuer("gopunch"), WithAudience("admin"))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if parsed.Header["alg"] != string(testCase.Algorithm) || parsed.Header["kid"] != "k1" ||
+			parsed.Claims.String("sub") != "svc" || parsed.Claims.String("scope") != "read" || parsed.Claims.String("jti") == "" {
+			t.Fatal(parsed)
+		}
+
+		t.Log("Given tampered claims; parse should report invalid signature")
+		parts := strings.Split(token, ".")
+		forged, _ := Sign(map[string]string{"alg": string(testCase.Algorithm)}, Claims{"sub": "admin"}, testCase.SigningKey)
+		tampered := parts[0] + "." + strings.Split(forged, ".")[1] + "." + parts[2]
+		if _, err := Parse(tampered, testCase.VerifyKey); !errors.Is(err, ErrInvalidSignature) {
+			t.Fatal(err)
+		}
+	}
+
+	t.Log("Given RS256 token verified with its public key as HMAC secret; parse should refuse the algorithm")
+	token, _, _ := Config{Algorithm: RS256, Key: rsaKey}.Mint()
+	if _, err := Parse(token, []byte("secret")); !errors.Is(err, ErrUnsupportedAlgorithm) {
+		t.Fatal(err)
+	}
+}
+
+func Test_ParseClaims(t *testing.T) {
+	now := withFrozenClock(t, time.Unix(1700000000, 0))
+	key := []byte("secret")
+
+	token, expiry, _ := Config{Algorithm: HS256, Key: key, TTL: time.Minute, Audience: []string{"api"}}.Mint()
+	if !expiry.Equal(now.Add(time.Minute)) {
+		t.Fatal(expiry)
+	}
+
+	t.Log("Given wrong issuer or audience; parse should report the claim")
+	if _, err := Parse(token, key, WithIssuer("other")); !errors.Is(err, ErrInvalidClaim) {
+		t.Fatal(err)
+	}
+
+	if _, err := Parse(token, key, WithAudience("other")); !errors.Is(err, ErrInvalidClaim) {
+		t.Fatal(err)
+	}
+
+	t.Log("Given expired token; parse should fail unless within the leeway")
+	*now = now.Add(time.Minute + time.Second)
+	if _, err := Parse(token, key); !errors.Is(err, ErrExpired) {
+		t.Fatal(err)
+	}
+
+	if _, err := Parse(token, key, WithLeeway(5*time.Second)); err != nil {
+		t.Fatal(err)
+	}
+
+	t.Log("Given token not valid yet; parse should fail")
+	future, _, _ := Config{Algorithm: HS256, Key: key, Claims: Claims{"nbf": now.Add(time.Hour).Unix()}}.Mint()
+	if _, err := Parse(future, key); !errors.Is(err, ErrNotValidYet) {
+		t.Fatal(err)
+	}
+
+	t.Log("Given garbage; parse should report malformed token")
+	if _, err := ParseUnverified("not-a-token"); !errors.Is(err, ErrMalformed) {
+		t.Fatal(err)
+	}
+}
+
+func Test_Auth(t *testing.T) {
+	ecKey, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+
+	var mu sync.Mutex
+	seen := map[string]int{}
+	api := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+		parsed, err := Parse(token, &ecKey.PublicKey, WithIssuer("gopunch"), WithAudience("internal"))
+		if err != nil || parsed.Claims.String("role") != "reader" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+
+		mu.Lock()
+		seen[token]++
+		mu.Unlock()
+	}))
+	defer api.Close()
+
+	get := func(client *gopunch.Client) {
+		resp := client.Get(context.Background(), "/items")
+		if resp.Err() != nil {
+			t.Fatal(resp.Err())
+		}
+		resp.Close()
+
+		if resp.HttpResponse().StatusCode != http.StatusOK {
+			t.Fatal(resp.HttpResponse().Status)
+		}
+	}
+
+	cfg := Config{Algorithm: ES256, Key: ecKey, Issuer: "gopunch", Audience: []string{"internal"}, Claims: Claims{"role": "reader"}}
+
+	t.Log("Given long lived token; every request should reuse the cached token")
+	client := gopunch.New(api.URL)
+	client.SetAuthenticator(Auth(cfg))
+	for i := 0; i < 3; i++ {
+		get(client)
+	}
+
+	if len(seen) != 1 {
+		t.Fatal(seen)
+	}
+
+	t.Log("Given token expiring within the refresh margin; every request should mint a new token")
+	cfg.TTL = gopunch.TokenExpiryDelta / 2
+	seen = map[string]int{}
+	client.SetAuthenticator(Auth(cfg))
+	for i := 0; i < 3; i++ {
+		get(client)
+	}
+
+	if len(seen) != 3 {
+		t.Fatal(seen)
+	}
+}
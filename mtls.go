@@ -0,0 +1,113 @@
+package gopunch
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+)
+
+var ErrNoCertificates = errors.New("no PEM certificates found")
+
+// certReloader serves the client certificate, reloading it when its files change
+type certReloader struct {
+	certFile string
+	keyFile  string
+	mu       sync.Mutex
+	cert     *tls.Certificate
+	stamp    string
+}
+
+func (r *certReloader) fileStamp() (string, error) {
+	var stamp string
+	for _, file := range []string{r.certFile, r.keyFile} {
+		info, err := os.Stat(file)
+		if err != nil {
+			return "", err
+		}
+		stamp += fmt.Sprintf("%d:%d;", info.ModTime().UnixNano(), info.Size())
+	}
+
+	return stamp, nil
+}
+
+// load reloads the pair when a file changed, a rotation caught halfway keeps the previous certificate
+func (r *certReloader) load() (*tls.Certificate, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	stamp, err := r.fileStamp()
+	if err == nil && stamp == r.stamp {
+		return r.cert, nil
+	}
+
+	if err == nil {
+		var cert tls.Certificate
+		if cert, err = tls.LoadX509KeyPair(r.certFile, r.keyFile); err == nil {
+			r.cert, r.stamp = &cert, stamp
+			return r.cert, nil
+		}
+	}
+
+	if r.cert != nil {
+		return r.cert, nil
+	}
+
+	return nil, err
+}
+
+func (r *certReloader) GetClientCertificate(*tls.CertificateRequestInfo) (*tls.Certificate, error) {
+	return r.load()
+}
+
+// SetClientCertificate
+//
+//	takes PEM encoded certificate and key files used for mutual TLS
+//	the files are checked on every new TLS handshake and reloaded once they change,
+//	open connections keep the certificate they were made with until they are closed
+//	returns error if the pair cannot be loaded or the client has a custom RoundTripper
+func (c *Client) SetClientCertificate(certFile, keyFile string) error {
+	reloader := &certReloader{certFile: certFile, keyFile: keyFile}
+	if _, err := reloader.load(); err != nil {
+		return err
+	}
+
+	cfg, err := c.tlsConfig()
+	if err != nil {
+		return err
+	}
+
+	cfg.GetClientCertificate = reloader.GetClientCertificate
+
+	return nil
+}
+
+// SetRootCAFiles
+//
+//	takes PEM bundles of the certificate authorities trusted to sign server certificates,
+//	system roots are no longer trusted once set
+//	returns error if a bundle cannot be read or holds no certificate
+func (c *Client) SetRootCAFiles(files ...string) error {
+	pool := x509.NewCertPool()
+	for _, file := range files {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return err
+		}
+
+		if !pool.AppendCertsFromPEM(data) {
+			return fmt.Errorf("%w in %s", ErrNoCertificates, file)
+		}
+	}
+
+	cfg, err := c.tlsConfig()
+	if err != nil {
+		return err
+	}
+
+	cfg.RootCAs = pool
+
+	return nil
+}
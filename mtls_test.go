@@ -0,0 +1,189 @@
+package gopunch
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// testCA issues certificates for local TLS servers and clients
+type testCA struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+	pem  []byte
+}
+
+func newTestCA(t *testing.T) *testCA {
+	key, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "test ca"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cert, _ := x509.ParseCertificate(der)
+
+	return &testCA{cert: cert, key: key, pem: pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})}
+}
+
+// issue returns a PEM certificate and key for commonName, valid for localhost and 127.0.0.1
+func (ca *testCA) issue(t *testing.T, commonName string) ([]byte, []byte) {
+	key, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	serial, _ := rand.Int(rand.Reader, big.NewInt(1<<62))
+	template := &x509.Certificate{
+		SerialNumber: serial,
+		Subject:      pkix.Name{CommonName: commonName},
+		DNSNames:     []string{"localhost", "example.com"},
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, ca.cert, &key.PublicKey, ca.key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	keyDER, _ := x509.MarshalECPrivateKey(key)
+
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}),
+		pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER})
+}
+
+// newTestTLSServer starts a TLS server with a certificate issued by ca, echoing the client certificate name
+func newTestTLSServer(t *testing.T, ca *testCA, clientAuth tls.ClientAuthType) *httptest.Server {
+	certPEM, keyPEM := ca.issue(t, "server")
+	cert, err := tls.X509KeyPair(certPEM, keyPEM)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	clientCAs := x509.NewCertPool()
+	clientCAs.AddCert(ca.cert)
+
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if len(r.TLS.PeerCertificates) > 0 {
+			io.WriteString(w, r.TLS.PeerCertificates[0].Subject.CommonName)
+		}
+	}))
+	server.TLS = &tls.Config{Certificates: []tls.Certificate{cert}, ClientAuth: clientAuth, ClientCAs: clientCAs}
+	server.StartTLS()
+
+	return server
+}
+
+func writeTestFile(t *testing.T, file string, data []byte, modTime time.Time) {
+	if err := os.WriteFile(file, data, 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	os.Chtimes(file, modTime, modTime)
+}
+
+func Test_ClientCertificate_Reload(t *testing.T) {
+	ca := newTestCA(t)
+	server := newTestTLSServer(t, ca, tls.RequireAndVerifyClientCert)
+	defer server.Close()
+
+	dir := t.TempDir()
+	caFile := filepath.Join(dir, "ca.pem")
+	certFile := filepath.Join(dir, "client.pem")
+	keyFile := filepath.Join(dir, "client.key")
+
+	writeTestFile(t, caFile, ca.pem, time.Now())
+	certPEM, keyPEM := ca.issue(t, "client-1")
+	writeTestFile(t, certFile, certPEM, time.Now().Add(-time.Hour))
+	writeTestFile(t, keyFile, keyPEM, time.Now().Add(-time.Hour))
+
+	client := New(server.URL)
+	if err := client.SetRootCAFiles(caFile); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := client.SetClientCertificate(certFile, keyFile); err != nil {
+		t.Fatal(err)
+	}
+
+	get := func() string {
+		resp := client.Get(context.Background(), "/")
+		if resp.Err() != nil {
+			t.Fatal(resp.Err())
+		}
+		defer resp.Close()
+
+		body, _ := io.ReadAll(resp.HttpResponse().Body)
+		return string(body)
+	}
+
+	t.Log("Given client certificate files; server should see the client certificate")
+	if name := get(); name != "client-1" {
+		t.Fatal(name)
+	}
+
+	t.Log("Given rotation caught halfway; previous certificate should still be used")
+	certPEM, keyPEM = ca.issue(t, "client-2")
+	writeTestFile(t, certFile, certPEM, time.Now())
+	client.HttpClient().CloseIdleConnections()
+	if name := get(); name != "client-1" {
+		t.Fatal(name)
+	}
+
+	t.Log("Given rotated files; new connections should use the new certificate without a new client")
+	writeTestFile(t, keyFile, keyPEM, time.Now())
+	client.HttpClient().CloseIdleConnections()
+	if name := get(); name != "client-2" {
+		t.Fatal(name)
+	}
+
+	t.Log("Given missing files or a bundle without certificates; setters should fail")
+	if err := client.SetClientCertificate(filepath.Join(dir, "missing.pem"), keyFile); err == nil {
+		t.Fail()
+	}
+
+	if err := client.SetRootCAFiles(keyFile); err == nil {
+		t.Fail()
+	}
+}
+
+func Test_RootCAFiles_Untrusted(t *testing.T) {
+	t.Log("Given server signed by another authority; handshake should fail")
+	server := newTestTLSServer(t, newTestCA(t), tls.NoClientCert)
+	defer server.Close()
+
+	caFile := filepath.Join(t.TempDir(), "ca.pem")
+	writeTestFile(t, caFile, newTestCA(t).pem, time.Now())
+
+	client := New(server.URL)
+	client.SetRootCAFiles(caFile)
+
+	resp := client.Get(context.Background(), "/")
+	if resp.Err() == nil {
+		resp.Close()
+		t.Fatal("expected certificate error")
+	}
+}
@@ -0,0 +1,227 @@
+// Package oauth2 fetches OAuth2 access tokens with gopunch, for use with gopunch.TokenAuth.
+package oauth2
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/haquenafeem/gopunch"
+)
+
+// AuthStyle
+//
+//	how client credentials are sent to the token endpoint
+type AuthStyle int
+
+const (
+	// AuthStyleHeader sends client id and secret with HTTP Basic auth
+	AuthStyleHeader AuthStyle = iota
+	// AuthStyleParams sends client id and secret in the form body
+	AuthStyleParams
+)
+
+// Config
+//
+//	describes the identity provider and the client registered with it
+type Config struct {
+	TokenURL     string
+	ClientID     string
+	ClientSecret string
+	Scopes       []string
+	Audience     string
+	AuthStyle    AuthStyle
+	// Client lends its *http.Client, and so its TLS, proxy and timeout settings, to the token requests
+	// TokenURL stays absolute whatever base url Client has, and Client's authenticator is not applied,
+	// so the client the tokens are for can be passed without the token requests waiting on themselves
+	// a plain *http.Client is used when nil
+	Client *gopunch.Client
+}
+
+// Error
+//
+//	error response of the token endpoint
+type Error struct {
+	StatusCode  int
+	Code        string `json:"error"`
+	Description string `json:"error_description"`
+	URI         string `json:"error_uri"`
+}
+
+func (e *Error) Error() string {
+	if e.Description == "" {
+		return fmt.Sprintf("oauth2: %s", e.Code)
+	}
+
+	return fmt.Sprintf("oauth2: %s: %s", e.Code, e.Description)
+}
+
+type tokenResponse struct {
+	AccessToken  string      `json:"access_token"`
+	TokenType    string      `json:"token_type"`
+	ExpiresIn    json.Number `json:"expires_in"`
+	RefreshToken string      `json:"refresh_token"`
+}
+
+// ClientCredentials
+//
+//	takes Config
+//	returns gopunch.TokenSource performing the client credentials grant
+func ClientCredentials(cfg Config) gopunch.TokenSource {
+	return gopunch.TokenSourceFunc(func(ctx context.Context) (*gopunch.Token, error) {
+		form := url.Values{"grant_type": {"client_credentials"}}
+		token, _, err := cfg.requestToken(ctx, form)
+		return token, err
+	})
+}
+
+// ClientCredentialsAuth
+//
+//	takes Config
+//	returns *gopunch.TokenAuth caching client credentials tokens until shortly before they expire
+func ClientCredentialsAuth(cfg Config) *gopunch.TokenAuth {
+	return gopunch.NewTokenAuth(ClientCredentials(cfg))
+}
+
+// RefreshTokenSource
+//
+//	performs the refresh token grant, keeping the latest refresh token
+//	when the provider rotates it
+type RefreshTokenSource struct {
+	mu           sync.Mutex
+	cfg          Config
+	refreshToken string
+}
+
+// RefreshToken
+//
+//	takes Config and a refresh token
+//	returns *RefreshTokenSource
+func RefreshToken(cfg Config, refreshToken string) *RefreshTokenSource {
+	return &RefreshTokenSource{cfg: cfg, refreshToken: refreshToken}
+}
+
+// Token
+//
+//	exchanges the refresh token for a new access token
+func (s *RefreshTokenSource) Token(ctx context.Context) (*gopunch.Token, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	form := url.Values{
+		"grant_type":    {"refresh_token"},
+		"refresh_token": {s.refreshToken},
+	}
+
+	token, refreshToken, err := s.cfg.requestToken(ctx, form)
+	if err != nil {
+		return nil, err
+	}
+
+	if refreshToken != "" {
+		s.refreshToken = refreshToken
+	}
+
+	return token, nil
+}
+
+// CurrentRefreshToken
+//
+//	returns the refresh token that will be used next, useful to persist rotated tokens
+func (s *RefreshTokenSource) CurrentRefreshToken() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.refreshToken
+}
+
+// RefreshTokenAuth
+//
+//	takes Config and a refresh token
+//	returns *gopunch.TokenAuth caching access tokens obtained with the refresh token grant
+func RefreshTokenAuth(cfg Config, refreshToken string) *gopunch.TokenAuth {
+	return gopunch.NewTokenAuth(RefreshToken(cfg, refreshToken))
+}
+
+func (cfg *Config) requestToken(ctx context.Context, form url.Values) (*gopunch.Token, string, error) {
+	if len(cfg.Scopes) > 0 {
+		form.Set("scope", strings.Join(cfg.Scopes, " "))
+	}
+
+	if cfg.Audience != "" {
+		form.Set("audience", cfg.Audience)
+	}
+
+	if cfg.AuthStyle == AuthStyleParams {
+		form.Set("client_id", cfg.ClientID)
+		if cfg.ClientSecret != "" {
+			form.Set("client_secret", cfg.ClientSecret)
+		}
+	}
+
+	client := gopunch.New(cfg.TokenURL)
+	if cfg.Client != nil {
+		client.SetHttpClient(cfg.Client.HttpClient())
+	}
+
+	opt := func(req *http.Request) {
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		req.Header.Set("Accept", "application/json")
+		if cfg.AuthStyle == AuthStyleHeader {
+			req.SetBasicAuth(url.QueryEscape(cfg.ClientID), url.QueryEscape(cfg.ClientSecret))
+		}
+	}
+
+	requestedAt := time.Now()
+	resp := client.Post(ctx, "", []byte(form.Encode()), opt)
+	defer resp.Close()
+
+	if resp.Err() != nil {
+		return nil, "", resp.Err()
+	}
+
+	httpResponse := resp.HttpResponse()
+	body, err := io.ReadAll(httpResponse.Body)
+	if err != nil {
+		return nil, "", err
+	}
+
+	if httpResponse.StatusCode < 200 || httpResponse.StatusCode > 299 {
+		tokenErr := &Error{StatusCode: httpResponse.StatusCode}
+		if json.Unmarshal(body, tokenErr) != nil || tokenErr.Code == "" {
+			return nil, "", &gopunch.StatusError{StatusCode: httpResponse.StatusCode, Status: httpResponse.Status}
+		}
+
+		return nil, "", tokenErr
+	}
+
+	var result tokenResponse
+	if err := json.Unmarshal(body, &result); err != nil {
+		return nil, "", err
+	}
+
+	token := &gopunch.Token{
+		AccessToken: result.AccessToken,
+		TokenType:   result.TokenType,
+	}
+
+	if result.ExpiresIn != "" {
+		seconds, err := strconv.ParseInt(string(result.ExpiresIn), 10, 64)
+		if err != nil {
+			return nil, "", fmt.Errorf("oauth2: invalid expires_in %q", result.ExpiresIn)
+		}
+
+		if seconds > 0 {
+			token.Expiry = requestedAt.Add(time.Duration(seconds) * time.Second)
+		}
+	}
+
+	return token, result.RefreshToken, nil
+}
@@ -0,0 +1,172 @@
+package oauth2
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"github.com/haquenafeem/gopunch"
+)
+
+// tokenServer is a local identity provider issuing numbered tokens
+func tokenServer(t *testing.T, issued *int32, check func(r *http.Request) bool) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		if !check(r) {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]string{"error": "invalid_client", "error_description": "bad credentials"})
+			return
+		}
+
+		n := atomic.AddInt32(issued, 1)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"access_token":  fmt.Sprintf("access-%d", n),
+			"token_type":    "bearer",
+			"expires_in":    "3600",
+			"refresh_token": fmt.Sprintf("refresh-%d", n),
+		})
+	}))
+}
+
+func Test_ClientCredentials(t *testing.T) {
+	t.Log("Given client credentials config; token should be fetched once and reused by concurrent API calls")
+	var issued int32
+	idp := tokenServer(t, &issued, func(r *http.Request) bool {
+		id, secret, ok := r.BasicAuth()
+		return ok && id == "svc" && secret == "s3cret" &&
+			r.PostForm.Get("grant_type") == "client_credentials" &&
+			r.PostForm.Get("scope") == "read write" &&
+			r.PostForm.Get("audience") == "https://api"
+	})
+	defer idp.Close()
+
+	api := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer access-1" {
+			w.WriteHeader(http.StatusUnauthorized)
+		}
+	}))
+	defer api.Close()
+
+	client := gopunch.New(api.URL)
+	client.SetAuthenticator(ClientCredentialsAuth(Config{
+		TokenURL:     idp.URL + "/token",
+		ClientID:     "svc",
+		ClientSecret: "s3cret",
+		Scopes:       []string{"read", "write"},
+		Audience:     "https://api",
+	}))
+
+	var wg sync.WaitGroup
+	var failures int32
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			resp := client.Get(context.Background(), "/items")
+			defer resp.Close()
+			if resp.Err() != nil || resp.HttpResponse().StatusCode != http.StatusOK {
+				atomic.AddInt32(&failures, 1)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if failures != 0 || issued != 1 {
+		t.Logf("failures %d, issued %d", failures, issued)
+		t.Fail()
+	}
+}
+
+func Test_ClientCredentials_Error(t *testing.T) {
+	t.Log("Given rejected credentials sent as params; typed oauth2 error should be returned")
+	var issued int32
+	idp := tokenServer(t, &issued, func(r *http.Request) bool {
+		return r.PostForm.Get("client_id") == "svc" && r.PostForm.Get("client_secret") == "right"
+	})
+	defer idp.Close()
+
+	_, err := ClientCredentials(Config{
+		TokenURL:     idp.URL,
+		ClientID:     "svc",
+		ClientSecret: "wrong",
+		AuthStyle:    AuthStyleParams,
+	}).Token(context.Background())
+
+	var tokenErr *Error
+	if !errors.As(err, &tokenErr) || tokenErr.Code != "invalid_client" || tokenErr.StatusCode != http.StatusBadRequest {
+		t.Fail()
+	}
+}
+
+func Test_RefreshToken(t *testing.T) {
+	t.Log("Given refresh token grant; rotated refresh tokens should be used for the next refresh")
+	var issued int32
+	var mu sync.Mutex
+	var seen []string
+	idp := tokenServer(t, &issued, func(r *http.Request) bool {
+		mu.Lock()
+		defer mu.Unlock()
+		seen = append(seen, r.PostForm.Get("refresh_token"))
+		return r.PostForm.Get("grant_type") == "refresh_token"
+	})
+	defer idp.Close()
+
+	source := RefreshToken(Config{TokenURL: idp.URL, ClientID: "app"}, "initial")
+
+	first, err := source.Token(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := source.Token(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	if first.AccessToken != "access-1" || first.Expiry.IsZero() {
+		t.Fail()
+	}
+
+	if len(seen) != 2 || seen[0] != "initial" || seen[1] != "refresh-1" || source.CurrentRefreshToken() != "refresh-2" {
+		t.Logf("refresh tokens sent %v", seen)
+		t.Fail()
+	}
+}
+
+func Test_ConfigClient(t *testing.T) {
+	t.Log("Given config client with its own base url and the token auth; token url should stay absolute without deadlocking")
+	var issued int32
+	idp := tokenServer(t, &issued, func(r *http.Request) bool {
+		return r.URL.Path == "/token" && r.PostForm.Get("grant_type") == "client_credentials"
+	})
+	defer idp.Close()
+
+	api := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer access-1" {
+			w.WriteHeader(http.StatusUnauthorized)
+		}
+	}))
+	defer api.Close()
+
+	client := gopunch.New(api.URL)
+	client.SetAuthenticator(ClientCredentialsAuth(Config{
+		TokenURL: idp.URL + "/token",
+		ClientID: "svc",
+		Client:   client,
+	}))
+
+	resp := client.Get(context.Background(), "/resource")
+	if resp.Err() != nil {
+		t.Fatal(resp.Err())
+	}
+	resp.Close()
+
+	if resp.HttpResponse().StatusCode != http.StatusOK || atomic.LoadInt32(&issued) != 1 {
+		t.Fatal(resp.HttpResponse().Status, issued)
+	}
+}
@@ -1,6 +1,8 @@
 package gopunch
 
 import (
+	"crypto/rand"
+	"fmt"
 	"net/http"
 )
 
@@ -32,3 +34,42 @@ func WithHeaders(headers map[string]string) Option {
 		}
 	}
 }
+
+// IdempotencyKeyHeader
+//
+//	header used to make unsafe requests safe to retry
+const IdempotencyKeyHeader = "Idempotency-Key"
+
+// WithIdempotencyKey
+//
+//	takes a key identifying one logical operation and sets it as Idempotency-Key
+//	the key stays the same across retries of the request
+func WithIdempotencyKey(key string) Option {
+	return func(req *http.Request) {
+		req.Header.Set(IdempotencyKeyHeader, key)
+	}
+}
+
+// WithNewIdempotencyKey
+//
+//	generates a random Idempotency-Key for the request
+func WithNewIdempotencyKey() Option {
+	return func(req *http.Request) {
+		req.Header.Set(IdempotencyKeyHeader, NewIdempotencyKey())
+	}
+}
+
+// NewIdempotencyKey
+//
+//	returns a random version 4 UUID
+func NewIdempotencyKey() string {
+	var b [16]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		panic(err)
+	}
+
+	b[6] = (b[6] & 0x0f) | 0x40
+	b[8] = (b[8] & 0x3f) | 0x80
+
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:16])
+}
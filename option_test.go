@@ -112,3 +112,25 @@ func Test_WithHeaders(t *testing.T) {
 		}
 	}
 }
+
+func Test_WithIdempotencyKey(t *testing.T) {
+	t.Log("Given fixed and generated idempotency keys; header should be set and generated keys should differ")
+	req, err := http.NewRequest("", "", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	WithIdempotencyKey("abc")(req)
+	if req.Header.Get(IdempotencyKeyHeader) != "abc" {
+		t.Fail()
+	}
+
+	WithNewIdempotencyKey()(req)
+	first := req.Header.Get(IdempotencyKeyHeader)
+	WithNewIdempotencyKey()(req)
+	second := req.Header.Get(IdempotencyKeyHeader)
+
+	if len(first) != 36 || first == second {
+		t.Fail()
+	}
+}
@@ -0,0 +1,454 @@
+package gopunch
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+var ErrIteratorDone = errors.New("no more items")
+
+// Page
+//
+//	a fetched page, handed to PageStrategy to work out the following one
+type Page struct {
+	Number int
+	URL    *url.URL
+	Query  map[string]string
+	Header http.Header
+	Body   []byte
+	Items  int
+}
+
+// PageTarget
+//
+//	where the next page lives, either an absolute URL or queries added to the endpoint
+type PageTarget struct {
+	URL   string
+	Query map[string]string
+}
+
+// PageStrategy
+//
+//	decides how pages are requested
+type PageStrategy interface {
+	// Start returns the target of the first page
+	Start() PageTarget
+	// Next returns the target of the page after page, false when there are no more pages
+	Next(page *Page) (PageTarget, bool, error)
+}
+
+type linkHeaderStrategy struct{}
+
+// LinkHeaderPagination
+//
+//	follows RFC 8288 Link headers with rel="next"
+func LinkHeaderPagination() PageStrategy {
+	return linkHeaderStrategy{}
+}
+
+func (linkHeaderStrategy) Start() PageTarget {
+	return PageTarget{}
+}
+
+func (linkHeaderStrategy) Next(page *Page) (PageTarget, bool, error) {
+	for _, line := range page.Header.Values("Link") {
+		next, ok := parseLinkHeader(line)["next"]
+		if !ok {
+			continue
+		}
+
+		nextURL, err := page.URL.Parse(next)
+		if err != nil {
+			return PageTarget{}, false, err
+		}
+
+		return PageTarget{URL: nextURL.String()}, true, nil
+	}
+
+	return PageTarget{}, false, nil
+}
+
+type cursorStrategy struct {
+	param   string
+	extract func(body []byte) (string, error)
+}
+
+// CursorPagination
+//
+//	takes the query parameter carrying the cursor and a function reading the next cursor from a page body
+//	pagination ends when the extracted cursor is empty
+func CursorPagination(param string, extract func(body []byte) (string, error)) PageStrategy {
+	return &cursorStrategy{param: param, extract: extract}
+}
+
+func (s *cursorStrategy) Start() PageTarget {
+	return PageTarget{}
+}
+
+func (s *cursorStrategy) Next(page *Page) (PageTarget, bool, error) {
+	cursor, err := s.extract(page.Body)
+	if err != nil || cursor == "" {
+		return PageTarget{}, false, err
+	}
+
+	return PageTarget{Query: map[string]string{s.param: cursor}}, true, nil
+}
+
+// CursorField
+//
+//	takes a dot separated path such as "meta.next_cursor"
+//	returns a cursor extractor for CursorPagination
+func CursorField(path string) func(body []byte) (string, error) {
+	return func(body []byte) (string, error) {
+		raw, err := jsonField(body, path)
+		if err != nil || raw == nil {
+			return "", err
+		}
+
+		// numbers are kept as written, ids above 2^53 would not survive a float64
+		decoder := json.NewDecoder(bytes.NewReader(raw))
+		decoder.UseNumber()
+
+		var cursor interface{}
+		if err := decoder.Decode(&cursor); err != nil {
+			return "", err
+		}
+
+		switch value := cursor.(type) {
+		case string:
+			return value, nil
+		case json.Number:
+			return value.String(), nil
+		}
+
+		return "", nil
+	}
+}
+
+type numberedStrategy struct {
+	param     string
+	sizeParam string
+	size      int
+	first     int
+	step      int
+}
+
+// PagePagination
+//
+//	requests pages with ?page=1&size=N style queries, pageParam starts at 1
+//	pagination ends on the first page holding less than size items
+func PagePagination(pageParam, sizeParam string, size int) PageStrategy {
+	return &numberedStrategy{param: pageParam, sizeParam: sizeParam, size: size, first: 1, step: 1}
+}
+
+// OffsetPagination
+//
+//	requests pages with ?offset=0&limit=N style queries
+//	pagination ends on the first page holding less than limit items
+func OffsetPagination(offsetParam, limitParam string, limit int) PageStrategy {
+	return &numberedStrategy{param: offsetParam, sizeParam: limitParam, size: limit, first: 0, step: limit}
+}
+
+func (s *numberedStrategy) target(value int) PageTarget {
+	return PageTarget{Query: map[string]string{
+		s.param:     strconv.Itoa(value),
+		s.sizeParam: strconv.Itoa(s.size),
+	}}
+}
+
+func (s *numberedStrategy) Start() PageTarget {
+	return s.target(s.first)
+}
+
+func (s *numberedStrategy) Next(page *Page) (PageTarget, bool, error) {
+	if page.Items < s.size {
+		return PageTarget{}, false, nil
+	}
+
+	return s.target(s.first + page.Number*s.step), true, nil
+}
+
+type paginatorConfig struct {
+	maxPages       int
+	prefetch       int
+	itemsField     string
+	requestOptions []Option
+}
+
+// PaginatorOption
+//
+//	can be used to customize a Paginator
+type PaginatorOption func(cfg *paginatorConfig)
+
+// WithMaxPages
+//
+//	takes the max number of pages to fetch
+func WithMaxPages(pages int) PaginatorOption {
+	return func(cfg *paginatorConfig) {
+		cfg.maxPages = pages
+	}
+}
+
+// WithPrefetch
+//
+//	takes how many extra pages to fetch whenever the buffered items run out
+//	pages are fetched inside Next, no goroutines are started
+func WithPrefetch(pages int) PaginatorOption {
+	return func(cfg *paginatorConfig) {
+		cfg.prefetch = pages
+	}
+}
+
+// WithItemsField
+//
+//	takes a dot separated path to the items array when the page body is an object
+func WithItemsField(path string) PaginatorOption {
+	return func(cfg *paginatorConfig) {
+		cfg.itemsField = path
+	}
+}
+
+// WithPageRequestOptions
+//
+//	takes option functions applied to every page request
+func WithPageRequestOptions(opts ...Option) PaginatorOption {
+	return func(cfg *paginatorConfig) {
+		cfg.requestOptions = append(cfg.requestOptions, opts...)
+	}
+}
+
+// Paginator
+//
+//	iterates over the decoded items of a paginated endpoint
+type Paginator[T any] struct {
+	client   *Client
+	endPoint string
+	strategy PageStrategy
+	cfg      *paginatorConfig
+	target   PageTarget
+	items    []T
+	pages    int
+	done     bool
+}
+
+// NewPaginator
+//
+//	takes client, endpoint, page strategy and paginator options
+//	returns *Paginator, nothing is requested until Next is called
+func NewPaginator[T any](c *Client, endPoint string, strategy PageStrategy, opts ...PaginatorOption) *Paginator[T] {
+	cfg := &paginatorConfig{}
+	for _, opt := range opts {
+		opt(cfg)
+	}
+
+	return &Paginator[T]{
+		client:   c,
+		endPoint: endPoint,
+		strategy: strategy,
+		cfg:      cfg,
+		target:   strategy.Start(),
+	}
+}
+
+// Next
+//
+//	returns the next item, fetching pages as needed
+//	returns ErrIteratorDone once every page has been consumed
+func (p *Paginator[T]) Next(ctx context.Context) (T, error) {
+	var zero T
+	for len(p.items) == 0 {
+		if p.done {
+			return zero, ErrIteratorDone
+		}
+
+		for i := 0; i <= p.cfg.prefetch && !p.done; i++ {
+			if err := p.fetch(ctx); err != nil {
+				return zero, err
+			}
+		}
+	}
+
+	item := p.items[0]
+	p.items = p.items[1:]
+
+	return item, nil
+}
+
+// All
+//
+//	returns every remaining item
+func (p *Paginator[T]) All(ctx context.Context) ([]T, error) {
+	var items []T
+	for {
+		item, err := p.Next(ctx)
+		if errors.Is(err, ErrIteratorDone) {
+			return items, nil
+		}
+
+		if err != nil {
+			return items, err
+		}
+
+		items = append(items, item)
+	}
+}
+
+// Pages
+//
+//	returns the number of pages fetched so far
+func (p *Paginator[T]) Pages() int {
+	return p.pages
+}
+
+func (p *Paginator[T]) fetch(ctx context.Context) error {
+	if p.cfg.maxPages > 0 && p.pages >= p.cfg.maxPages {
+		p.done = true
+		return nil
+	}
+
+	var resp *Response
+	if p.target.URL != "" {
+		resp = p.client.getURL(ctx, p.target.URL, p.cfg.requestOptions...)
+	} else {
+		opts := append([]Option{}, p.cfg.requestOptions...)
+		if len(p.target.Query) > 0 {
+			opts = append(opts, WithQueries(p.target.Query))
+		}
+		resp = p.client.Get(ctx, p.endPoint, opts...)
+	}
+	defer resp.Close()
+
+	if resp.Err() != nil {
+		return resp.Err()
+	}
+
+	httpResponse := resp.HttpResponse()
+	if httpResponse.StatusCode < 200 || httpResponse.StatusCode > 299 {
+		return &StatusError{StatusCode: httpResponse.StatusCode, Status: httpResponse.Status}
+	}
+
+	body, err := io.ReadAll(httpResponse.Body)
+	if err != nil {
+		return err
+	}
+
+	raw := json.RawMessage(body)
+	if p.cfg.itemsField != "" {
+		if raw, err = jsonField(body, p.cfg.itemsField); err != nil {
+			return err
+		}
+	}
+
+	var items []T
+	if len(raw) > 0 {
+		if err := json.Unmarshal(raw, &items); err != nil {
+			return err
+		}
+	}
+
+	p.pages++
+	page := &Page{
+		Number: p.pages,
+		URL:    httpResponse.Request.URL,
+		Query:  p.target.Query,
+		Header: httpResponse.Header,
+		Body:   body,
+		Items:  len(items),
+	}
+
+	next, ok, err := p.strategy.Next(page)
+	if err != nil {
+		return err
+	}
+
+	p.done = !ok
+	p.target = next
+	p.items = append(p.items, items...)
+
+	return nil
+}
+
+func (c *Client) getURL(ctx context.Context, completeUrl string, opts ...Option) *Response {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, completeUrl, nil)
+	if err != nil {
+		return NewResponse(nil, err)
+	}
+
+	for _, opt := range opts {
+		opt(req)
+	}
+
+	return c.do(req)
+}
+
+// jsonField returns the raw value at a dot separated path, nil when it is missing
+func jsonField(body []byte, path string) (json.RawMessage, error) {
+	raw := json.RawMessage(body)
+	for _, name := range strings.Split(path, ".") {
+		var object map[string]json.RawMessage
+		if err := json.Unmarshal(raw, &object); err != nil {
+			return nil, err
+		}
+
+		var ok bool
+		if raw, ok = object[name]; !ok {
+			return nil, nil
+		}
+	}
+
+	return raw, nil
+}
+
+// parseLinkHeader maps every rel of a Link header to its target
+func parseLinkHeader(header string) map[string]string {
+	links := map[string]string{}
+	for _, link := range splitLinkValues(header) {
+		link = strings.TrimSpace(link)
+		end := strings.Index(link, ">")
+		if !strings.HasPrefix(link, "<") || end < 0 {
+			continue
+		}
+
+		target := link[1:end]
+		for _, param := range strings.Split(link[end+1:], ";") {
+			name, value, ok := strings.Cut(strings.TrimSpace(param), "=")
+			if !ok || !strings.EqualFold(strings.TrimSpace(name), "rel") {
+				continue
+			}
+
+			for _, rel := range strings.Fields(strings.Trim(strings.TrimSpace(value), `"`)) {
+				links[strings.ToLower(rel)] = target
+			}
+		}
+	}
+
+	return links
+}
+
+// splitLinkValues splits on commas that are outside of <> and quotes
+func splitLinkValues(header string) []string {
+	var values []string
+	inURL, inQuotes, start := false, false, 0
+	for i, r := range header {
+		switch {
+		case r == '<' && !inQuotes:
+			inURL = true
+		case r == '>' && !inQuotes:
+			inURL = false
+		case r == '"' && !inURL:
+			inQuotes = !inQuotes
+		case r == ',' && !inURL && !inQuotes:
+			values = append(values, header[start:i])
+			start = i + 1
+		}
+	}
+
+	return append(values, header[start:])
+}
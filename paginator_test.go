@@ -0,0 +1,210 @@
+package gopunch
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync/atomic"
+	"testing"
+)
+
+// paginatedServer serves the numbers 1..total in several pagination styles
+func paginatedServer(total int, requests *int32) *httptest.Server {
+	numbers := make([]int, total)
+	for i := range numbers {
+		numbers[i] = i + 1
+	}
+
+	slice := func(offset, limit int) []int {
+		if offset > total {
+			offset = total
+		}
+		end := offset + limit
+		if end > total {
+			end = total
+		}
+		return numbers[offset:end]
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/link", func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(requests, 1)
+		page, _ := strconv.Atoi(r.URL.Query().Get("p"))
+		if page == 0 {
+			page = 1
+		}
+		items := slice((page-1)*3, 3)
+		if page*3 < total {
+			w.Header().Set("Link", fmt.Sprintf(`</link?p=1>; rel="first", </link?p=%d>; rel="next"`, page+1))
+		}
+		json.NewEncoder(w).Encode(items)
+	})
+	mux.HandleFunc("/cursor", func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(requests, 1)
+		offset, _ := strconv.Atoi(r.URL.Query().Get("after"))
+		items := slice(offset, 4)
+		next := ""
+		if offset+4 < total {
+			next = strconv.Itoa(offset + 4)
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"data": items,
+			"meta": map[string]string{"next": next},
+		})
+	})
+	mux.HandleFunc("/pages", func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(requests, 1)
+		page, _ := strconv.Atoi(r.URL.Query().Get("page"))
+		size, _ := strconv.Atoi(r.URL.Query().Get("size"))
+		json.NewEncoder(w).Encode(slice((page-1)*size, size))
+	})
+	mux.HandleFunc("/offset", func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(requests, 1)
+		offset, _ := strconv.Atoi(r.URL.Query().Get("offset"))
+		limit, _ := strconv.Atoi(r.URL.Query().Get("limit"))
+		json.NewEncoder(w).Encode(slice(offset, limit))
+	})
+
+	return httptest.NewServer(mux)
+}
+
+var PaginatorTestCases = []struct {
+	Title    string
+	Path     string
+	Strategy PageStrategy
+	Options  []PaginatorOption
+	Expected int
+}{
+	{
+		Title:    "Given Link header pagination; paginator should follow rel=next until it disappears",
+		Path:     "/link",
+		Strategy: LinkHeaderPagination(),
+		Expected: 10,
+	},
+	{
+		Title:    "Given cursor pagination with items and cursor inside the body; every item should be returned",
+		Path:     "/cursor",
+		Strategy: CursorPagination("after", CursorField("meta.next")),
+		Options:  []PaginatorOption{WithItemsField("data")},
+		Expected: 10,
+	},
+	{
+		Title:    "Given page/size pagination; paginator should stop on the first short page",
+		Path:     "/pages",
+		Strategy: PagePagination("page", "size", 4),
+		Expected: 10,
+	},
+	{
+		Title:    "Given offset/limit pagination with prefetch; every item should be returned in order",
+		Path:     "/offset",
+		Strategy: OffsetPagination("offset", "limit", 3),
+		Options:  []PaginatorOption{WithPrefetch(2)},
+		Expected: 10,
+	},
+	{
+		Title:    "Given max pages of 2; paginator should stop early",
+		Path:     "/link",
+		Strategy: LinkHeaderPagination(),
+		Options:  []PaginatorOption{WithMaxPages(2)},
+		Expected: 6,
+	},
+}
+
+func Test_Paginator(t *testing.T) {
+	var requests int32
+	server := paginatedServer(10, &requests)
+	defer server.Close()
+
+	client := New(server.URL)
+	ctx := context.Background()
+
+	for _, testCase := range PaginatorTestCases {
+		t.Log(testCase.Title)
+		paginator := NewPaginator[int](client, testCase.Path, testCase.Strategy, testCase.Options...)
+
+		items, err := paginator.All(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if len(items) != testCase.Expected {
+			t.Logf("expected %d items, got %d", testCase.Expected, len(items))
+			t.Fail()
+		}
+
+		for i, item := range items {
+			if item != i+1 {
+				t.Fail()
+			}
+		}
+
+		if _, err := paginator.Next(ctx); !errors.Is(err, ErrIteratorDone) {
+			t.Fail()
+		}
+	}
+}
+
+func Test_Paginator_Lazy(t *testing.T) {
+	t.Log("Given paginator; pages should only be fetched when items run out, with prefetch fetching extra pages at once")
+	var requests int32
+	server := paginatedServer(10, &requests)
+	defer server.Close()
+
+	ctx := context.Background()
+	paginator := NewPaginator[int](New(server.URL), "/offset", OffsetPagination("offset", "limit", 2), WithPrefetch(1))
+	if atomic.LoadInt32(&requests) != 0 {
+		t.Fail()
+	}
+
+	if _, err := paginator.Next(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	if atomic.LoadInt32(&requests) != 2 || paginator.Pages() != 2 {
+		t.Logf("expected 2 requests, got %d", requests)
+		t.Fail()
+	}
+}
+
+func Test_ParseLinkHeader(t *testing.T) {
+	t.Log("Given Link header with several links and quoted commas; every rel should be mapped")
+	links := parseLinkHeader(`<https://api.example.com/items?page=2>; rel="next", <https://api.example.com/items?page=5>; rel="last"; title="a, b", <https://api.example.com/items?page=1>; rel="first prev"`)
+
+	expected := map[string]string{
+		"next":  "https://api.example.com/items?page=2",
+		"last":  "https://api.example.com/items?page=5",
+		"first": "https://api.example.com/items?page=1",
+		"prev":  "https://api.example.com/items?page=1",
+	}
+
+	for rel, target := range expected {
+		if links[rel] != target {
+			t.Logf("rel %s: expected %s, got %s", rel, target, links[rel])
+			t.Fail()
+		}
+	}
+}
+
+func Test_CursorField(t *testing.T) {
+	testCases := []struct {
+		Title    string
+		Body     string
+		Expected string
+	}{
+		{Title: "Given string cursor; it should be returned as is", Body: `{"meta":{"next":"abc"}}`, Expected: "abc"},
+		{Title: "Given numeric cursor above 2^53; it should keep every digit", Body: `{"meta":{"next":1234567890123456789}}`, Expected: "1234567890123456789"},
+		{Title: "Given null cursor; pagination should end", Body: `{"meta":{"next":null}}`, Expected: ""},
+	}
+
+	for _, testCase := range testCases {
+		t.Log(testCase.Title)
+		cursor, err := CursorField("meta.next")([]byte(testCase.Body))
+		if err != nil || cursor != testCase.Expected {
+			t.Fatal(cursor, err)
+		}
+	}
+}
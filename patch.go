@@ -0,0 +1,273 @@
+package gopunch
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const (
+	ContentTypeJSONPatch  = "application/json-patch+json"
+	ContentTypeMergePatch = "application/merge-patch+json"
+)
+
+// JSONPatchOperation
+//
+//	single RFC 6902 operation
+type JSONPatchOperation struct {
+	Op    string          `json:"op"`
+	Path  string          `json:"path"`
+	From  string          `json:"from,omitempty"`
+	Value json.RawMessage `json:"value,omitempty"`
+}
+
+// CreateJSONPatch
+//
+//	takes original and modified values, raw JSON bytes are used as is
+//	returns the RFC 6902 JSON Patch turning original into modified
+func CreateJSONPatch(original, modified interface{}) ([]byte, error) {
+	from, err := toJSONValue(original)
+	if err != nil {
+		return nil, err
+	}
+
+	to, err := toJSONValue(modified)
+	if err != nil {
+		return nil, err
+	}
+
+	operations := []JSONPatchOperation{}
+	if err := diffJSONPatch("", from, to, &operations); err != nil {
+		return nil, err
+	}
+
+	return json.Marshal(operations)
+}
+
+// CreateMergePatch
+//
+//	takes original and modified values, raw JSON bytes are used as is
+//	returns the RFC 7396 JSON Merge Patch turning original into modified
+//	merge patches cannot set a member to null, null means removal
+func CreateMergePatch(original, modified interface{}) ([]byte, error) {
+	from, err := toJSONValue(original)
+	if err != nil {
+		return nil, err
+	}
+
+	to, err := toJSONValue(modified)
+	if err != nil {
+		return nil, err
+	}
+
+	fromObject, fromOk := from.(map[string]interface{})
+	toObject, toOk := to.(map[string]interface{})
+	if !fromOk || !toOk {
+		return json.Marshal(to)
+	}
+
+	return json.Marshal(diffMergePatch(fromObject, toObject))
+}
+
+// JSONPatch
+//
+//	takes context, endpoint, original and modified values and option functions
+//	sends the RFC 6902 difference between them through Patch
+//	returns *Response
+func (c *Client) JSONPatch(ctx context.Context, endPoint string, original, modified interface{}, opts ...Option) *Response {
+	payload, err := CreateJSONPatch(original, modified)
+	if err != nil {
+		return NewResponse(nil, err)
+	}
+
+	return c.Patch(ctx, endPoint, payload, append(opts, setHeader("Content-Type", ContentTypeJSONPatch))...)
+}
+
+// MergePatch
+//
+//	takes context, endpoint, original and modified values and option functions
+//	sends the RFC 7396 difference between them through Patch
+//	returns *Response
+func (c *Client) MergePatch(ctx context.Context, endPoint string, original, modified interface{}, opts ...Option) *Response {
+	payload, err := CreateMergePatch(original, modified)
+	if err != nil {
+		return NewResponse(nil, err)
+	}
+
+	return c.Patch(ctx, endPoint, payload, append(opts, setHeader("Content-Type", ContentTypeMergePatch))...)
+}
+
+func setHeader(key, value string) Option {
+	return func(req *http.Request) {
+		req.Header.Set(key, value)
+	}
+}
+
+func toJSONValue(v interface{}) (interface{}, error) {
+	var data []byte
+	switch raw := v.(type) {
+	case []byte:
+		data = raw
+	case json.RawMessage:
+		data = raw
+	default:
+		var err error
+		data, err = json.Marshal(v)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return decodeJSONValue(data)
+}
+
+func decodeJSONValue(data []byte) (interface{}, error) {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+
+	var value interface{}
+	if err := decoder.Decode(&value); err != nil {
+		return nil, err
+	}
+
+	return value, nil
+}
+
+func escapePointerToken(token string) string {
+	return strings.NewReplacer("~", "~0", "/", "~1").Replace(token)
+}
+
+func sortedKeys(object map[string]interface{}) []string {
+	keys := make([]string, 0, len(object))
+	for key := range object {
+		keys = append(keys, key)
+	}
+
+	sort.Strings(keys)
+
+	return keys
+}
+
+func diffJSONPatch(path string, from, to interface{}, operations *[]JSONPatchOperation) error {
+	if reflect.DeepEqual(from, to) {
+		return nil
+	}
+
+	appendOperation := func(op, path string, value interface{}) error {
+		operation := JSONPatchOperation{Op: op, Path: path}
+		if op != "remove" {
+			raw, err := json.Marshal(value)
+			if err != nil {
+				return err
+			}
+			operation.Value = raw
+		}
+
+		*operations = append(*operations, operation)
+
+		return nil
+	}
+
+	switch fromValue := from.(type) {
+	case map[string]interface{}:
+		toValue, ok := to.(map[string]interface{})
+		if !ok {
+			break
+		}
+
+		for _, key := range sortedKeys(fromValue) {
+			if _, ok := toValue[key]; !ok {
+				if err := appendOperation("remove", path+"/"+escapePointerToken(key), nil); err != nil {
+					return err
+				}
+			}
+		}
+
+		for _, key := range sortedKeys(toValue) {
+			childPath := path + "/" + escapePointerToken(key)
+			child, ok := fromValue[key]
+			if !ok {
+				if err := appendOperation("add", childPath, toValue[key]); err != nil {
+					return err
+				}
+				continue
+			}
+
+			if err := diffJSONPatch(childPath, child, toValue[key], operations); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	case []interface{}:
+		toValue, ok := to.([]interface{})
+		if !ok {
+			break
+		}
+
+		common := len(fromValue)
+		if len(toValue) < common {
+			common = len(toValue)
+		}
+
+		for i := 0; i < common; i++ {
+			if err := diffJSONPatch(path+"/"+strconv.Itoa(i), fromValue[i], toValue[i], operations); err != nil {
+				return err
+			}
+		}
+
+		for i := common; i < len(toValue); i++ {
+			if err := appendOperation("add", path+"/"+strconv.Itoa(i), toValue[i]); err != nil {
+				return err
+			}
+		}
+
+		// remove from the end so earlier indexes stay valid
+		for i := len(fromValue) - 1; i >= common; i-- {
+			if err := appendOperation("remove", path+"/"+strconv.Itoa(i), nil); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	}
+
+	return appendOperation("replace", path, to)
+}
+
+func diffMergePatch(from, to map[string]interface{}) map[string]interface{} {
+	patch := map[string]interface{}{}
+	for key := range from {
+		if _, ok := to[key]; !ok {
+			patch[key] = nil
+		}
+	}
+
+	for key, toValue := range to {
+		fromValue, ok := from[key]
+		if !ok {
+			patch[key] = toValue
+			continue
+		}
+
+		fromObject, fromOk := fromValue.(map[string]interface{})
+		toObject, toOk := toValue.(map[string]interface{})
+		if fromOk && toOk {
+			if child := diffMergePatch(fromObject, toObject); len(child) > 0 {
+				patch[key] = child
+			}
+			continue
+		}
+
+		if !reflect.DeepEqual(fromValue, toValue) {
+			patch[key] = toValue
+		}
+	}
+
+	return patch
+}
@@ -0,0 +1,299 @@
+package gopunch
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+var ErrPatchTestFailed = errors.New("json patch test operation failed")
+
+// ApplyJSONPatch
+//
+//	takes a JSON document and an RFC 6902 JSON Patch
+//	returns the patched document, the original is left untouched
+func ApplyJSONPatch(document, patch []byte) ([]byte, error) {
+	root, err := decodeJSONValue(document)
+	if err != nil {
+		return nil, err
+	}
+
+	var operations []JSONPatchOperation
+	if err := json.Unmarshal(patch, &operations); err != nil {
+		return nil, err
+	}
+
+	for i, operation := range operations {
+		root, err = applyPatchOperation(root, operation)
+		if err != nil {
+			return nil, fmt.Errorf("operation %d (%s %s): %w", i, operation.Op, operation.Path, err)
+		}
+	}
+
+	return json.Marshal(root)
+}
+
+// ApplyMergePatch
+//
+//	takes a JSON document and an RFC 7396 JSON Merge Patch
+//	returns the patched document
+func ApplyMergePatch(document, patch []byte) ([]byte, error) {
+	target, err := decodeJSONValue(document)
+	if err != nil {
+		return nil, err
+	}
+
+	patchValue, err := decodeJSONValue(patch)
+	if err != nil {
+		return nil, err
+	}
+
+	return json.Marshal(applyMergePatch(target, patchValue))
+}
+
+func applyMergePatch(target, patch interface{}) interface{} {
+	patchObject, ok := patch.(map[string]interface{})
+	if !ok {
+		return patch
+	}
+
+	targetObject, ok := target.(map[string]interface{})
+	if !ok {
+		targetObject = map[string]interface{}{}
+	}
+
+	for key, value := range patchObject {
+		if value == nil {
+			delete(targetObject, key)
+			continue
+		}
+
+		targetObject[key] = applyMergePatch(targetObject[key], value)
+	}
+
+	return targetObject
+}
+
+func applyPatchOperation(root interface{}, operation JSONPatchOperation) (interface{}, error) {
+	path, err := parsePointer(operation.Path)
+	if err != nil {
+		return nil, err
+	}
+
+	switch operation.Op {
+	case "add", "replace", "test":
+		if operation.Value == nil {
+			return nil, errors.New("missing value")
+		}
+
+		value, err := decodeJSONValue(operation.Value)
+		if err != nil {
+			return nil, err
+		}
+
+		switch operation.Op {
+		case "add":
+			return pointerAdd(root, path, value)
+		case "replace":
+			if len(path) == 0 {
+				return value, nil
+			}
+			root, _, err = pointerRemove(root, path)
+			if err != nil {
+				return nil, err
+			}
+			return pointerAdd(root, path, value)
+		}
+
+		current, err := pointerGet(root, path)
+		if err != nil {
+			return nil, err
+		}
+
+		if !reflect.DeepEqual(current, value) {
+			return nil, ErrPatchTestFailed
+		}
+
+		return root, nil
+	case "remove":
+		root, _, err = pointerRemove(root, path)
+		return root, err
+	case "move", "copy":
+		from, err := parsePointer(operation.From)
+		if err != nil {
+			return nil, err
+		}
+
+		var value interface{}
+		if operation.Op == "move" {
+			if strings.HasPrefix(operation.Path+"/", operation.From+"/") && operation.Path != operation.From {
+				return nil, errors.New("cannot move a value into one of its children")
+			}
+			root, value, err = pointerRemove(root, from)
+		} else {
+			value, err = pointerGet(root, from)
+			if err == nil {
+				value, err = deepCopyJSON(value)
+			}
+		}
+
+		if err != nil {
+			return nil, err
+		}
+
+		return pointerAdd(root, path, value)
+	}
+
+	return nil, fmt.Errorf("unknown operation %q", operation.Op)
+}
+
+func parsePointer(pointer string) ([]string, error) {
+	if pointer == "" {
+		return nil, nil
+	}
+
+	if pointer[0] != '/' {
+		return nil, fmt.Errorf("invalid json pointer %q", pointer)
+	}
+
+	tokens := strings.Split(pointer[1:], "/")
+	unescape := strings.NewReplacer("~1", "/", "~0", "~")
+	for i, token := range tokens {
+		tokens[i] = unescape.Replace(token)
+	}
+
+	return tokens, nil
+}
+
+func arrayIndex(token string, length int) (int, error) {
+	index, err := strconv.Atoi(token)
+	if err != nil || index < 0 || index >= length || (len(token) > 1 && token[0] == '0') {
+		return 0, fmt.Errorf("invalid array index %q", token)
+	}
+
+	return index, nil
+}
+
+func pointerGet(node interface{}, path []string) (interface{}, error) {
+	for _, token := range path {
+		switch current := node.(type) {
+		case map[string]interface{}:
+			child, ok := current[token]
+			if !ok {
+				return nil, fmt.Errorf("member %q not found", token)
+			}
+			node = child
+		case []interface{}:
+			index, err := arrayIndex(token, len(current))
+			if err != nil {
+				return nil, err
+			}
+			node = current[index]
+		default:
+			return nil, fmt.Errorf("cannot traverse into %q", token)
+		}
+	}
+
+	return node, nil
+}
+
+// updateParent walks to the parent of path and lets leaf replace it
+func updateParent(node interface{}, path []string, leaf func(parent interface{}, token string) (interface{}, error)) (interface{}, error) {
+	if len(path) == 1 {
+		return leaf(node, path[0])
+	}
+
+	child, err := pointerGet(node, path[:1])
+	if err != nil {
+		return nil, err
+	}
+
+	child, err = updateParent(child, path[1:], leaf)
+	if err != nil {
+		return nil, err
+	}
+
+	switch current := node.(type) {
+	case map[string]interface{}:
+		current[path[0]] = child
+	case []interface{}:
+		index, _ := arrayIndex(path[0], len(current))
+		current[index] = child
+	}
+
+	return node, nil
+}
+
+func pointerAdd(root interface{}, path []string, value interface{}) (interface{}, error) {
+	if len(path) == 0 {
+		return value, nil
+	}
+
+	return updateParent(root, path, func(parent interface{}, token string) (interface{}, error) {
+		switch current := parent.(type) {
+		case map[string]interface{}:
+			current[token] = value
+			return current, nil
+		case []interface{}:
+			index := len(current)
+			if token != "-" {
+				var err error
+				index, err = arrayIndex(token, len(current)+1)
+				if err != nil {
+					return nil, err
+				}
+			}
+
+			current = append(current, nil)
+			copy(current[index+1:], current[index:])
+			current[index] = value
+
+			return current, nil
+		}
+
+		return nil, fmt.Errorf("cannot add %q to a scalar", token)
+	})
+}
+
+func pointerRemove(root interface{}, path []string) (interface{}, interface{}, error) {
+	if len(path) == 0 {
+		return nil, nil, errors.New("cannot remove the whole document")
+	}
+
+	var removed interface{}
+	root, err := updateParent(root, path, func(parent interface{}, token string) (interface{}, error) {
+		switch current := parent.(type) {
+		case map[string]interface{}:
+			value, ok := current[token]
+			if !ok {
+				return nil, fmt.Errorf("member %q not found", token)
+			}
+			removed = value
+			delete(current, token)
+			return current, nil
+		case []interface{}:
+			index, err := arrayIndex(token, len(current))
+			if err != nil {
+				return nil, err
+			}
+			removed = current[index]
+			return append(current[:index:index], current[index+1:]...), nil
+		}
+
+		return nil, fmt.Errorf("cannot remove %q from a scalar", token)
+	})
+
+	return root, removed, err
+}
+
+func deepCopyJSON(value interface{}) (interface{}, error) {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return nil, err
+	}
+
+	return decodeJSONValue(data)
+}
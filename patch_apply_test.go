@@ -0,0 +1,96 @@
+package gopunch
+
+import (
+	"errors"
+	"testing"
+)
+
+var ApplyJSONPatchTestCases = []struct {
+	Title       string
+	Document    string
+	Patch       string
+	Expected    string
+	ExpectedErr bool
+}{
+	{
+		Title:    "Given add into array by index; value should be inserted",
+		Document: `{"foo":["bar","baz"]}`,
+		Patch:    `[{"op":"add","path":"/foo/1","value":"qux"}]`,
+		Expected: `{"foo":["bar","qux","baz"]}`,
+	},
+	{
+		Title:    "Given add with - index; value should be appended",
+		Document: `{"foo":[1]}`,
+		Patch:    `[{"op":"add","path":"/foo/-","value":2}]`,
+		Expected: `{"foo":[1,2]}`,
+	},
+	{
+		Title:    "Given remove and replace; members should be updated",
+		Document: `{"baz":"qux","foo":"bar"}`,
+		Patch:    `[{"op":"replace","path":"/baz","value":"boo"},{"op":"remove","path":"/foo"}]`,
+		Expected: `{"baz":"boo"}`,
+	},
+	{
+		Title:    "Given move and copy; values should be relocated and duplicated",
+		Document: `{"foo":{"bar":"baz","waldo":"fred"},"qux":{"corge":"grault"}}`,
+		Patch:    `[{"op":"move","from":"/foo/waldo","path":"/qux/thud"},{"op":"copy","from":"/foo/bar","path":"/copied"}]`,
+		Expected: `{"foo":{"bar":"baz"},"qux":{"corge":"grault","thud":"fred"},"copied":"baz"}`,
+	},
+	{
+		Title:    "Given successful test operation; document should be unchanged",
+		Document: `{"baz":"qux","foo":["a",2,"c"]}`,
+		Patch:    `[{"op":"test","path":"/baz","value":"qux"},{"op":"test","path":"/foo/1","value":2}]`,
+		Expected: `{"baz":"qux","foo":["a",2,"c"]}`,
+	},
+	{
+		Title:       "Given failing test operation; apply should return error",
+		Document:    `{"baz":"qux"}`,
+		Patch:       `[{"op":"test","path":"/baz","value":"bar"}]`,
+		ExpectedErr: true,
+	},
+	{
+		Title:       "Given remove of missing member; apply should return error",
+		Document:    `{"baz":"qux"}`,
+		Patch:       `[{"op":"remove","path":"/missing"}]`,
+		ExpectedErr: true,
+	},
+}
+
+func Test_ApplyJSONPatch(t *testing.T) {
+	for _, testCase := range ApplyJSONPatchTestCases {
+		t.Log(testCase.Title)
+		patched, err := ApplyJSONPatch([]byte(testCase.Document), []byte(testCase.Patch))
+		if testCase.ExpectedErr {
+			if err == nil {
+				t.Fail()
+			}
+			continue
+		}
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		assertSameJSON(t, []byte(testCase.Expected), patched)
+	}
+
+	t.Log("Given failing test operation; error should wrap ErrPatchTestFailed")
+	_, err := ApplyJSONPatch([]byte(`{"a":1}`), []byte(`[{"op":"test","path":"/a","value":2}]`))
+	if !errors.Is(err, ErrPatchTestFailed) {
+		t.Fail()
+	}
+}
+
+func Test_ApplyMergePatch(t *testing.T) {
+	t.Log("Given RFC 7396 example; merge patch should replace, remove and add members")
+	document := `{"title":"Goodbye!","author":{"givenName":"John","familyName":"Doe"},"tags":["example","sample"],"content":"text"}`
+	patch := `{"title":"Hello!","phoneNumber":"+01-123-456-7890","author":{"familyName":null},"tags":["example"]}`
+	expected := `{"title":"Hello!","author":{"givenName":"John"},"tags":["example"],"content":"text","phoneNumber":"+01-123-456-7890"}`
+
+	patched, err := ApplyMergePatch([]byte(document), []byte(patch))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assertSameJSON(t, []byte(expected), patched)
+}
@@ -0,0 +1,144 @@
+package gopunch
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+var PatchCreateTestCases = []struct {
+	Title    string
+	Original string
+	Modified string
+}{
+	{
+		Title:    "Given objects with changed, added and removed members; applying the created patch should produce modified",
+		Original: `{"title":"a","done":false,"tags":["x"]}`,
+		Modified: `{"title":"b","tags":["x"],"owner":{"id":1}}`,
+	},
+	{
+		Title:    "Given arrays that grow and shrink; applying the created patch should produce modified",
+		Original: `{"items":[1,2,3],"nested":{"list":[{"a":1},{"a":2}]}}`,
+		Modified: `{"items":[1,5],"nested":{"list":[{"a":1},{"a":3},{"a":4}]}}`,
+	},
+	{
+		Title:    "Given keys needing pointer escaping; applying the created patch should produce modified",
+		Original: `{"a/b":1,"m~n":2}`,
+		Modified: `{"a/b":3}`,
+	},
+}
+
+func assertSameJSON(t *testing.T, expected, actual []byte) {
+	var e, a interface{}
+	if err := json.Unmarshal(expected, &e); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := json.Unmarshal(actual, &a); err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(e, a) {
+		t.Logf("expected %s, got %s", expected, actual)
+		t.Fail()
+	}
+}
+
+func Test_CreateJSONPatch(t *testing.T) {
+	for _, testCase := range PatchCreateTestCases {
+		t.Log(testCase.Title)
+		patch, err := CreateJSONPatch([]byte(testCase.Original), []byte(testCase.Modified))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		patched, err := ApplyJSONPatch([]byte(testCase.Original), patch)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		assertSameJSON(t, []byte(testCase.Modified), patched)
+	}
+}
+
+func Test_CreateMergePatch(t *testing.T) {
+	for _, testCase := range PatchCreateTestCases {
+		t.Log(testCase.Title)
+		patch, err := CreateMergePatch([]byte(testCase.Original), []byte(testCase.Modified))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		patched, err := ApplyMergePatch([]byte(testCase.Original), patch)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		assertSameJSON(t, []byte(testCase.Modified), patched)
+	}
+
+	t.Log("Given go structs; merge patch should only contain changed fields")
+	type todo struct {
+		Title string `json:"title"`
+		Done  bool   `json:"done"`
+	}
+
+	patch, err := CreateMergePatch(todo{Title: "a"}, todo{Title: "a", Done: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assertSameJSON(t, []byte(`{"done":true}`), patch)
+}
+
+func Test_Client_JSONPatch_MergePatch(t *testing.T) {
+	t.Log("Given server applying patches locally; client patch helpers should send the right Content-Type and document")
+	document := []byte(`{"title":"a","done":false}`)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		patch, _ := io.ReadAll(r.Body)
+
+		var result []byte
+		var err error
+		switch r.Header.Get("Content-Type") {
+		case ContentTypeJSONPatch:
+			result, err = ApplyJSONPatch(document, patch)
+		case ContentTypeMergePatch:
+			result, err = ApplyMergePatch(document, patch)
+		default:
+			w.WriteHeader(http.StatusUnsupportedMediaType)
+			return
+		}
+
+		if err != nil {
+			w.WriteHeader(http.StatusUnprocessableEntity)
+			return
+		}
+
+		w.Write(result)
+	}))
+	defer server.Close()
+
+	client := New(server.URL)
+	ctx := context.Background()
+	original := map[string]interface{}{"title": "a", "done": false}
+	modified := map[string]interface{}{"title": "b", "done": false}
+
+	for _, resp := range []*Response{
+		client.JSONPatch(ctx, "/todos/1", original, modified),
+		client.MergePatch(ctx, "/todos/1", original, modified),
+	} {
+		var m map[string]interface{}
+		if err := resp.JSONUnmarshal(&m); err != nil {
+			t.Fatal(err)
+		}
+		resp.Close()
+
+		if !reflect.DeepEqual(m, modified) {
+			t.Fail()
+		}
+	}
+}
@@ -0,0 +1,149 @@
+package gopunch
+
+import (
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"fmt"
+	"log"
+	"strings"
+)
+
+// PinError
+//
+//	returned when no certificate of the server's chain matches the pins of its host
+type PinError struct {
+	Host string
+	// Chain holds the SPKI pins of the certificates the server presented
+	Chain []string
+}
+
+func (e *PinError) Error() string {
+	return fmt.Sprintf("certificate pin mismatch for %s, server presented %s", e.Host, strings.Join(e.Chain, ", "))
+}
+
+type pinning struct {
+	pins       map[string]map[string]bool
+	reportOnly bool
+	report     func(err *PinError)
+}
+
+// PinOption
+//
+//	can be used to customize SetPins
+type PinOption func(p *pinning)
+
+// WithPinReportOnly
+//
+//	mismatches are handed to report instead of failing the handshake,
+//	logged with the standard logger when report is nil
+func WithPinReportOnly(report func(err *PinError)) PinOption {
+	return func(p *pinning) {
+		p.reportOnly = true
+		p.report = report
+	}
+}
+
+// SPKIPin
+//
+//	returns the base64 SHA-256 of the certificate's public key, the format expected by SetPins
+func SPKIPin(cert *x509.Certificate) string {
+	sum := sha256.Sum256(cert.RawSubjectPublicKeyInfo)
+	return base64.StdEncoding.EncodeToString(sum[:])
+}
+
+// SetPins
+//
+//	takes pins per host, a host may be "api.example.com" or "*.example.com" for its subdomains
+//	pins are SPKIPin values, optionally prefixed with "sha256/"
+//	a connection to a pinned host fails with *PinError unless some certificate of its chain matches
+//	hosts are matched against the TLS server name, so servers addressed by IP cannot be pinned
+//	replaces the VerifyConnection of the TLS config, pass nil to stop pinning
+//	returns error if the client has a custom RoundTripper
+func (c *Client) SetPins(pins map[string][]string, opts ...PinOption) error {
+	p := &pinning{pins: map[string]map[string]bool{}}
+	for host, hostPins := range pins {
+		set := map[string]bool{}
+		for _, pin := range hostPins {
+			set[strings.TrimPrefix(pin, "sha256/")] = true
+		}
+		p.pins[strings.ToLower(host)] = set
+	}
+
+	for _, opt := range opts {
+		opt(p)
+	}
+
+	cfg, err := c.tlsConfig()
+	if err != nil {
+		return err
+	}
+
+	if len(p.pins) == 0 {
+		cfg.VerifyConnection = nil
+		return nil
+	}
+
+	cfg.VerifyConnection = p.verify
+
+	return nil
+}
+
+func (p *pinning) hostPins(host string) map[string]bool {
+	host = strings.ToLower(host)
+	if pins, ok := p.pins[host]; ok {
+		return pins
+	}
+
+	for i := strings.IndexByte(host, '.'); i >= 0; i = strings.IndexByte(host, '.') {
+		host = host[i+1:]
+		if pins, ok := p.pins["*."+host]; ok {
+			return pins
+		}
+	}
+
+	return nil
+}
+
+func (p *pinning) verify(state tls.ConnectionState) error {
+	pins := p.hostPins(state.ServerName)
+	if pins == nil {
+		return nil
+	}
+
+	// verified chains are empty when verification is skipped, the presented chain is checked instead
+	chains := state.VerifiedChains
+	if len(chains) == 0 {
+		chains = [][]*x509.Certificate{state.PeerCertificates}
+	}
+
+	var presented []string
+	seen := map[string]bool{}
+	for _, chain := range chains {
+		for _, cert := range chain {
+			pin := SPKIPin(cert)
+			if pins[pin] {
+				return nil
+			}
+
+			if !seen[pin] {
+				seen[pin] = true
+				presented = append(presented, pin)
+			}
+		}
+	}
+
+	pinErr := &PinError{Host: state.ServerName, Chain: presented}
+	if !p.reportOnly {
+		return pinErr
+	}
+
+	if p.report != nil {
+		p.report(pinErr)
+	} else {
+		log.Printf("gopunch: %v", pinErr)
+	}
+
+	return nil
+}
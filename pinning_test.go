@@ -0,0 +1,90 @@
+package gopunch
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"net/url"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func Test_SetPins(t *testing.T) {
+	ca := newTestCA(t)
+	server := newTestTLSServer(t, ca, tls.NoClientCert)
+	defer server.Close()
+
+	caFile := filepath.Join(t.TempDir(), "ca.pem")
+	writeTestFile(t, caFile, ca.pem, time.Now())
+
+	// pinned hosts are names, so the server is addressed as localhost
+	u, _ := url.Parse(server.URL)
+	u.Host = "localhost:" + u.Port()
+
+	leafPin := SPKIPin(server.Certificate())
+	caPin := SPKIPin(ca.cert)
+	otherPin := SPKIPin(newTestCA(t).cert)
+
+	testCases := []struct {
+		Title    string
+		Pins     map[string][]string
+		Mismatch bool
+		Reported bool
+	}{
+		{Title: "Given leaf pin; request should succeed", Pins: map[string][]string{"localhost": {leafPin}}},
+		{Title: "Given pin of the issuing CA; request should succeed", Pins: map[string][]string{"localhost": {otherPin, "sha256/" + caPin}}},
+		{Title: "Given pins of another host only; request should succeed", Pins: map[string][]string{"example.com": {otherPin}}},
+		{Title: "Given wrong pin; request should fail with *PinError", Pins: map[string][]string{"localhost": {otherPin}}, Mismatch: true},
+		{
+			Title:    "Given wrong pin in report only mode; request should succeed and mismatch should be reported",
+			Pins:     map[string][]string{"localhost": {otherPin}},
+			Reported: true,
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Log(testCase.Title)
+		client := New(u.String())
+		if err := client.SetRootCAFiles(caFile); err != nil {
+			t.Fatal(err)
+		}
+
+		var reported *PinError
+		var opts []PinOption
+		if testCase.Reported {
+			opts = []PinOption{WithPinReportOnly(func(err *PinError) { reported = err })}
+		}
+
+		if err := client.SetPins(testCase.Pins, opts...); err != nil {
+			t.Fatal(err)
+		}
+
+		resp := client.Get(context.Background(), "/")
+		var pinErr *PinError
+		if testCase.Mismatch {
+			if !errors.As(resp.Err(), &pinErr) || pinErr.Host != "localhost" || !strings.Contains(pinErr.Error(), leafPin) {
+				t.Fatal(resp.Err())
+			}
+			continue
+		}
+
+		if resp.Err() != nil {
+			t.Fatal(resp.Err())
+		}
+		resp.Close()
+
+		if testCase.Reported && (reported == nil || len(reported.Chain) != 2) {
+			t.Fatal(reported)
+		}
+	}
+}
+
+func Test_Pinning_HostPins(t *testing.T) {
+	t.Log("Given wildcard pins; subdomains should match but the bare domain should not")
+	p := &pinning{pins: map[string]map[string]bool{"*.example.com": {"a": true}, "api.example.com": {"b": true}}}
+	if !p.hostPins("API.example.com")["b"] || !p.hostPins("a.b.example.com")["a"] || p.hostPins("example.com") != nil {
+		t.Fail()
+	}
+}
@@ -0,0 +1,141 @@
+package gopunch
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// SetProxy
+//
+//	takes the proxy url and NO_PROXY style entries of hosts reached directly
+//	the url may be http://, https:// or socks5:// with optional user:password credentials
+//	no proxy entries may be "*", a host such as "example.com" which covers its subdomains,
+//	".example.com" or "*.example.com" for subdomains only, "host:port", an IP address or a CIDR such as "10.0.0.0/8"
+//	returns error if the url is invalid or the client has a custom RoundTripper
+func (c *Client) SetProxy(proxyURL string, noProxy ...string) error {
+	proxy, err := url.Parse(proxyURL)
+	if err != nil {
+		return err
+	}
+
+	switch proxy.Scheme {
+	case "http", "https", "socks5", "socks5h":
+	default:
+		return fmt.Errorf("unsupported proxy scheme %q", proxy.Scheme)
+	}
+
+	bypass := parseNoProxy(noProxy)
+
+	return c.SetProxyFunc(func(req *http.Request) (*url.URL, error) {
+		if bypass.match(req.URL) {
+			return nil, nil
+		}
+
+		return proxy, nil
+	})
+}
+
+// SetProxyFunc
+//
+//	takes a function picking the proxy of each request, a nil url sends the request directly
+//	pass nil to disable proxies, http.ProxyFromEnvironment restores the default
+//	returns error if the client has a custom RoundTripper
+func (c *Client) SetProxyFunc(proxy func(req *http.Request) (*url.URL, error)) error {
+	transport, err := c.transport()
+	if err != nil {
+		return err
+	}
+
+	transport.Proxy = proxy
+
+	return nil
+}
+
+type noProxyRule struct {
+	domain      string
+	subdomainOf bool
+	port        string
+	network     *net.IPNet
+}
+
+type noProxyRules struct {
+	all   bool
+	rules []noProxyRule
+}
+
+func parseNoProxy(entries []string) *noProxyRules {
+	rules := &noProxyRules{}
+	for _, entry := range entries {
+		for _, field := range strings.Split(entry, ",") {
+			field = strings.ToLower(strings.TrimSpace(field))
+			if field == "" {
+				continue
+			}
+
+			if field == "*" {
+				rules.all = true
+				continue
+			}
+
+			if _, network, err := net.ParseCIDR(field); err == nil {
+				rules.rules = append(rules.rules, noProxyRule{network: network})
+				continue
+			}
+
+			var rule noProxyRule
+			if host, port, err := net.SplitHostPort(field); err == nil {
+				field, rule.port = host, port
+			}
+
+			if ip := net.ParseIP(strings.Trim(field, "[]")); ip != nil {
+				rule.network = &net.IPNet{IP: ip, Mask: net.CIDRMask(len(ip)*8, len(ip)*8)}
+				rules.rules = append(rules.rules, rule)
+				continue
+			}
+
+			// "*.example.com" is the same as ".example.com", subdomains only
+			field = strings.TrimPrefix(field, "*")
+			rule.subdomainOf = strings.HasPrefix(field, ".")
+			rule.domain = strings.TrimPrefix(field, ".")
+			rules.rules = append(rules.rules, rule)
+		}
+	}
+
+	return rules
+}
+
+// match reports whether u must be reached without the proxy
+func (r *noProxyRules) match(u *url.URL) bool {
+	if r.all {
+		return true
+	}
+
+	host := strings.ToLower(u.Hostname())
+	port := u.Port()
+	if port == "" {
+		port = map[string]string{"http": "80", "https": "443", "ws": "80", "wss": "443"}[u.Scheme]
+	}
+
+	ip := net.ParseIP(host)
+	for _, rule := range r.rules {
+		if rule.port != "" && rule.port != port {
+			continue
+		}
+
+		if rule.network != nil {
+			if ip != nil && rule.network.Contains(ip) {
+				return true
+			}
+			continue
+		}
+
+		if strings.HasSuffix(host, "."+rule.domain) || (!rule.subdomainOf && host == rule.domain) {
+			return true
+		}
+	}
+
+	return false
+}
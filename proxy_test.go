@@ -0,0 +1,248 @@
+package gopunch
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"encoding/binary"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path/filepath"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// newTestHTTPProxy forwards plain requests and tunnels CONNECT, requiring basic proxy credentials
+func newTestHTTPProxy(hits *int32) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(hits, 1)
+
+		proxyReq := &http.Request{Header: http.Header{"Authorization": r.Header.Values("Proxy-Authorization")}}
+		if user, pass, ok := proxyReq.BasicAuth(); !ok || user != "proxy-user" || pass != "proxy-pass" {
+			w.Header().Set("Proxy-Authenticate", `Basic realm="proxy"`)
+			w.WriteHeader(http.StatusProxyAuthRequired)
+			return
+		}
+
+		if r.Method == http.MethodConnect {
+			target, err := net.Dial("tcp", r.Host)
+			if err != nil {
+				w.WriteHeader(http.StatusBadGateway)
+				return
+			}
+
+			w.WriteHeader(http.StatusOK)
+			conn, buffered, _ := w.(http.Hijacker).Hijack()
+			go func() {
+				io.Copy(target, buffered)
+				target.Close()
+			}()
+			io.Copy(conn, target)
+			conn.Close()
+			return
+		}
+
+		outgoing, _ := http.NewRequest(r.Method, r.URL.String(), r.Body)
+		outgoing.Header = r.Header.Clone()
+		outgoing.Header.Del("Proxy-Authorization")
+
+		resp, err := (&http.Transport{}).RoundTrip(outgoing)
+		if err != nil {
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		}
+		defer resp.Body.Close()
+
+		for key, values := range resp.Header {
+			w.Header()[key] = values
+		}
+		w.Header().Set("Via", "1.1 test-proxy")
+		w.WriteHeader(resp.StatusCode)
+		io.Copy(w, resp.Body)
+	}))
+}
+
+// newTestSOCKS5Proxy serves RFC 1928 CONNECT with RFC 1929 username/password auth
+func newTestSOCKS5Proxy(t *testing.T, hits *int32) net.Listener {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	serve := func(conn net.Conn) {
+		defer conn.Close()
+		reader := bufio.NewReader(conn)
+
+		header := make([]byte, 2)
+		io.ReadFull(reader, header)
+		io.ReadFull(reader, make([]byte, header[1]))
+		conn.Write([]byte{5, 2})
+
+		readString := func() string {
+			length, _ := reader.ReadByte()
+			value := make([]byte, length)
+			io.ReadFull(reader, value)
+			return string(value)
+		}
+
+		reader.ReadByte()
+		if readString() != "socks-user" || readString() != "socks-pass" {
+			conn.Write([]byte{1, 1})
+			return
+		}
+		conn.Write([]byte{1, 0})
+
+		request := make([]byte, 4)
+		io.ReadFull(reader, request)
+
+		var host string
+		switch request[3] {
+		case 1:
+			ip := make([]byte, 4)
+			io.ReadFull(reader, ip)
+			host = net.IP(ip).String()
+		case 3:
+			host = readString()
+		}
+
+		port := make([]byte, 2)
+		io.ReadFull(reader, port)
+
+		target, err := net.Dial("tcp", net.JoinHostPort(host, strconv.Itoa(int(binary.BigEndian.Uint16(port)))))
+		if err != nil {
+			conn.Write([]byte{5, 5, 0, 1, 0, 0, 0, 0, 0, 0})
+			return
+		}
+		defer target.Close()
+
+		atomic.AddInt32(hits, 1)
+		conn.Write([]byte{5, 0, 0, 1, 0, 0, 0, 0, 0, 0})
+		go io.Copy(target, reader)
+		io.Copy(conn, target)
+	}
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go serve(conn)
+		}
+	}()
+
+	return listener
+}
+
+func Test_SetProxy(t *testing.T) {
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, "target")
+	}))
+	defer target.Close()
+
+	ca := newTestCA(t)
+	tlsTarget := newTestTLSServer(t, ca, tls.NoClientCert)
+	defer tlsTarget.Close()
+
+	caFile := filepath.Join(t.TempDir(), "ca.pem")
+	writeTestFile(t, caFile, ca.pem, time.Now())
+
+	var httpHits, socksHits int32
+	httpProxy := newTestHTTPProxy(&httpHits)
+	defer httpProxy.Close()
+
+	socksProxy := newTestSOCKS5Proxy(t, &socksHits)
+	defer socksProxy.Close()
+
+	proxyURL, _ := url.Parse(httpProxy.URL)
+	proxyURL.User = url.UserPassword("proxy-user", "proxy-pass")
+
+	testCases := []struct {
+		Title     string
+		BaseURL   string
+		Proxy     string
+		NoProxy   []string
+		Status    int
+		Via       bool
+		HTTPHits  int32
+		SOCKSHits int32
+	}{
+		{Title: "Given http proxy with credentials; request should be forwarded", BaseURL: target.URL, Proxy: proxyURL.String(), Status: http.StatusOK, Via: true, HTTPHits: 1},
+		{Title: "Given http proxy and https target; request should be tunneled with CONNECT", BaseURL: tlsTarget.URL, Proxy: proxyURL.String(), Status: http.StatusOK, HTTPHits: 1},
+		{Title: "Given http proxy without credentials; proxy should answer 407", BaseURL: target.URL, Proxy: httpProxy.URL, Status: http.StatusProxyAuthRequired, HTTPHits: 1},
+		{Title: "Given target in no proxy; request should go direct", BaseURL: target.URL, Proxy: proxyURL.String(), NoProxy: []string{"example.com, 127.0.0.0/8"}, Status: http.StatusOK},
+		{Title: "Given socks5 proxy with credentials; request should be tunneled", BaseURL: target.URL, Proxy: "socks5://socks-user:socks-pass@" + socksProxy.Addr().String(), Status: http.StatusOK, SOCKSHits: 1},
+	}
+
+	for _, testCase := range testCases {
+		t.Log(testCase.Title)
+		atomic.StoreInt32(&httpHits, 0)
+		atomic.StoreInt32(&socksHits, 0)
+
+		client := New(testCase.BaseURL)
+		client.SetRootCAFiles(caFile)
+		if err := client.SetProxy(testCase.Proxy, testCase.NoProxy...); err != nil {
+			t.Fatal(err)
+		}
+
+		resp := client.Get(context.Background(), "/")
+		if resp.Err() != nil {
+			t.Fatal(resp.Err())
+		}
+		resp.Close()
+
+		httpResponse := resp.HttpResponse()
+		if httpResponse.StatusCode != testCase.Status || (httpResponse.Header.Get("Via") != "") != testCase.Via {
+			t.Fatal(httpResponse.Status, httpResponse.Header)
+		}
+
+		if atomic.LoadInt32(&httpHits) != testCase.HTTPHits || atomic.LoadInt32(&socksHits) != testCase.SOCKSHits {
+			t.Fatal(httpHits, socksHits)
+		}
+	}
+
+	t.Log("Given unsupported scheme; SetProxy should fail")
+	if err := New(target.URL).SetProxy("ftp://proxy"); err == nil {
+		t.Fail()
+	}
+}
+
+var NoProxyTestCases = []struct {
+	Title    string
+	URL      string
+	Expected bool
+}{
+	{Title: "Given exact host; it should bypass", URL: "http://example.com/", Expected: true},
+	{Title: "Given subdomain of host entry; it should bypass", URL: "https://api.example.com/", Expected: true},
+	{Title: "Given bare domain of a dot entry; it should use the proxy", URL: "http://internal/", Expected: false},
+	{Title: "Given subdomain of a dot entry; it should bypass", URL: "http://db.internal/", Expected: true},
+	{Title: "Given address in CIDR; it should bypass", URL: "http://10.1.2.3:8080/", Expected: true},
+	{Title: "Given host with matching port; it should bypass", URL: "https://cache.local/", Expected: true},
+	{Title: "Given host with another port; it should use the proxy", URL: "http://cache.local/", Expected: false},
+	{Title: "Given ipv6 literal entry; it should bypass", URL: "http://[::1]:9000/", Expected: true},
+	{Title: "Given unrelated host; it should use the proxy", URL: "http://notexample.com/", Expected: false},
+	{Title: "Given apex of a wildcard entry; it should use the proxy", URL: "http://corp.net/", Expected: false},
+	{Title: "Given subdomain of a wildcard entry; it should bypass", URL: "http://git.corp.net/", Expected: true},
+}
+
+func Test_NoProxy(t *testing.T) {
+	rules := parseNoProxy([]string{"example.com,.internal", " 10.0.0.0/8 ", "cache.local:443", "::1", "*.corp.net"})
+	for _, testCase := range NoProxyTestCases {
+		t.Log(testCase.Title)
+		u, _ := url.Parse(testCase.URL)
+		if rules.match(u) != testCase.Expected {
+			t.Fail()
+		}
+	}
+
+	t.Log("Given wildcard; every host should bypass")
+	u, _ := url.Parse("http://anything/")
+	if !parseNoProxy([]string{"*"}).match(u) {
+		t.Fail()
+	}
+}
@@ -0,0 +1,197 @@
+package gopunch
+
+import (
+	"context"
+	"errors"
+	"net"
+	"strings"
+	"sync"
+	"time"
+)
+
+var ErrNoAddresses = errors.New("resolver returned no addresses")
+
+// Resolver
+//
+//	looks up the addresses of a host, *net.Resolver satisfies it
+type Resolver interface {
+	LookupHost(ctx context.Context, host string) ([]string, error)
+}
+
+// ResolverFunc
+//
+//	lets a plain function be used as Resolver
+type ResolverFunc func(ctx context.Context, host string) ([]string, error)
+
+func (f ResolverFunc) LookupHost(ctx context.Context, host string) ([]string, error) {
+	return f(ctx, host)
+}
+
+type resolved struct {
+	addrs   []string
+	expires time.Time
+}
+
+// CachingResolver
+//
+//	keeps the addresses returned by another Resolver for a fixed TTL, failed lookups are not cached
+//	safe for concurrent use
+type CachingResolver struct {
+	resolver Resolver
+	ttl      time.Duration
+	mu       sync.Mutex
+	cache    map[string]resolved
+}
+
+// NewCachingResolver
+//
+//	takes the Resolver to cache, net.DefaultResolver for system DNS, and how long answers are kept
+//	returns *CachingResolver
+func NewCachingResolver(resolver Resolver, ttl time.Duration) *CachingResolver {
+	return &CachingResolver{
+		resolver: resolver,
+		ttl:      ttl,
+		cache:    map[string]resolved{},
+	}
+}
+
+// LookupHost
+//
+//	returns the cached addresses of host, looking them up once they expired
+func (r *CachingResolver) LookupHost(ctx context.Context, host string) ([]string, error) {
+	host = strings.ToLower(host)
+
+	r.mu.Lock()
+	entry, ok := r.cache[host]
+	r.mu.Unlock()
+
+	if ok && nowFunc().Before(entry.expires) {
+		return entry.addrs, nil
+	}
+
+	addrs, err := r.resolver.LookupHost(ctx, host)
+	if err != nil {
+		return nil, err
+	}
+
+	r.mu.Lock()
+	r.cache[host] = resolved{addrs: addrs, expires: nowFunc().Add(r.ttl)}
+	r.mu.Unlock()
+
+	return addrs, nil
+}
+
+// Flush
+//
+//	drops every cached answer
+func (r *CachingResolver) Flush() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.cache = map[string]resolved{}
+}
+
+// hostDialer picks the address to dial from host overrides or a resolver,
+// the url is left untouched so TLS server name and Host header keep the original host
+type hostDialer struct {
+	dial      DialContextFunc
+	overrides map[string]string
+	resolver  Resolver
+}
+
+func (d *hostDialer) DialContext(ctx context.Context, network, addr string) (net.Conn, error) {
+	dial := d.dial
+	if dial == nil {
+		dial = (&net.Dialer{Timeout: 30 * time.Second, KeepAlive: 30 * time.Second}).DialContext
+	}
+
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return dial(ctx, network, addr)
+	}
+
+	if target, ok := d.overrides[strings.ToLower(addr)]; ok {
+		return dial(ctx, network, target)
+	}
+
+	if target, ok := d.overrides[strings.ToLower(host)]; ok {
+		return dial(ctx, network, net.JoinHostPort(target, port))
+	}
+
+	if d.resolver == nil || net.ParseIP(host) != nil {
+		return dial(ctx, network, addr)
+	}
+
+	addrs, err := d.resolver.LookupHost(ctx, host)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(addrs) == 0 {
+		return nil, &net.DNSError{Err: ErrNoAddresses.Error(), Name: host}
+	}
+
+	// addresses are tried in order until one accepts the connection
+	for _, ip := range addrs {
+		var conn net.Conn
+		if conn, err = dial(ctx, network, net.JoinHostPort(ip, port)); err == nil {
+			return conn, nil
+		}
+	}
+
+	return nil, err
+}
+
+// hostDialer returns the dialer of the client, installing it in the transport on first use
+// and keeping the dial function the transport had
+func (c *Client) hostDialer() (*hostDialer, error) {
+	transport, err := c.transport()
+	if err != nil {
+		return nil, err
+	}
+
+	if c.dialer == nil {
+		c.dialer = &hostDialer{dial: transport.DialContext}
+	}
+
+	transport.DialContext = c.dialer.DialContext
+
+	return c.dialer, nil
+}
+
+// SetHostOverrides
+//
+//	takes a map of hosts to the addresses to connect to instead, like curl --resolve
+//	keys are "host" or "host:port", values an IP for "host" keys and "ip:port" for "host:port" keys
+//	the url keeps the original host, so TLS verification and the Host header are unchanged
+//	pass nil to remove the overrides
+//	returns error if the client has a custom RoundTripper
+func (c *Client) SetHostOverrides(overrides map[string]string) error {
+	dialer, err := c.hostDialer()
+	if err != nil {
+		return err
+	}
+
+	dialer.overrides = map[string]string{}
+	for host, addr := range overrides {
+		dialer.overrides[strings.ToLower(host)] = addr
+	}
+
+	return nil
+}
+
+// SetResolver
+//
+//	sets the Resolver looking up hosts without an override, wrap it with NewCachingResolver to cache answers
+//	pass nil to let the dialer resolve hosts
+//	returns error if the client has a custom RoundTripper
+func (c *Client) SetResolver(resolver Resolver) error {
+	dialer, err := c.hostDialer()
+	if err != nil {
+		return err
+	}
+
+	dialer.resolver = resolver
+
+	return nil
+}
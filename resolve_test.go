@@ -0,0 +1,147 @@
+package gopunch
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func Test_SetHostOverrides(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, r.Host)
+	}))
+	defer server.Close()
+
+	_, port, _ := net.SplitHostPort(server.Listener.Addr().String())
+
+	t.Log("Given host override; request should reach the address and keep the original Host header")
+	client := New("http://API.example.test:" + port)
+	client.SetProxyFunc(nil)
+	if err := client.SetHostOverrides(map[string]string{"api.example.test": "127.0.0.1"}); err != nil {
+		t.Fatal(err)
+	}
+
+	resp := client.Get(context.Background(), "/")
+	if resp.Err() != nil {
+		t.Fatal(resp.Err())
+	}
+
+	body, _ := io.ReadAll(resp.HttpResponse().Body)
+	resp.Close()
+	if string(body) != "API.example.test:"+port {
+		t.Fatal(string(body))
+	}
+
+	t.Log("Given host:port override to a TLS server; certificate should be verified against the original host")
+	ca := newTestCA(t)
+	tlsServer := newTestTLSServer(t, ca, tls.NoClientCert)
+	defer tlsServer.Close()
+
+	caFile := filepath.Join(t.TempDir(), "ca.pem")
+	writeTestFile(t, caFile, ca.pem, time.Now())
+
+	client = New("https://example.com")
+	client.SetProxyFunc(nil)
+	client.SetRootCAFiles(caFile)
+	client.SetHostOverrides(map[string]string{"example.com:443": tlsServer.Listener.Addr().String()})
+
+	resp = client.Get(context.Background(), "/")
+	if resp.Err() != nil {
+		t.Fatal(resp.Err())
+	}
+	resp.Close()
+
+	if resp.HttpResponse().TLS.ServerName != "example.com" {
+		t.Fatal(resp.HttpResponse().TLS.ServerName)
+	}
+}
+
+func Test_SetResolver(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, r.Host)
+	}))
+	defer server.Close()
+
+	_, port, _ := net.SplitHostPort(server.Listener.Addr().String())
+
+	t.Log("Given resolver returning an unreachable address first; the next address should be dialed")
+	var lookups int32
+	resolver := ResolverFunc(func(ctx context.Context, host string) ([]string, error) {
+		atomic.AddInt32(&lookups, 1)
+		if host != "blue.example.test" {
+			return nil, &net.DNSError{Err: "no such host", Name: host, IsNotFound: true}
+		}
+		return []string{"::1", "127.0.0.1"}, nil
+	})
+
+	client := New("http://blue.example.test:" + port)
+	client.SetProxyFunc(nil)
+	client.SetResolver(resolver)
+
+	resp := client.Get(context.Background(), "/")
+	if resp.Err() != nil {
+		t.Fatal(resp.Err())
+	}
+	resp.Close()
+
+	if atomic.LoadInt32(&lookups) != 1 {
+		t.Fatal(lookups)
+	}
+
+	t.Log("Given unknown host; the resolver error should be returned")
+	client.SetBaseURL("http://green.example.test:" + port)
+	resp = client.Get(context.Background(), "/")
+	var dnsErr *net.DNSError
+	if !errors.As(resp.Err(), &dnsErr) || !dnsErr.IsNotFound {
+		t.Fatal(resp.Err())
+	}
+}
+
+func Test_CachingResolver(t *testing.T) {
+	t.Log("Given caching resolver; answers should be reused until the TTL expires and errors not cached")
+	now := withFrozenClock(t, time.Unix(1700000000, 0))
+
+	var lookups int32
+	fail := false
+	resolver := NewCachingResolver(ResolverFunc(func(ctx context.Context, host string) ([]string, error) {
+		atomic.AddInt32(&lookups, 1)
+		if fail {
+			return nil, errors.New("dns down")
+		}
+		return []string{"10.0.0.1"}, nil
+	}), time.Minute)
+
+	lookup := func() error {
+		_, err := resolver.LookupHost(context.Background(), "Svc.example.test")
+		return err
+	}
+
+	lookup()
+	*now = now.Add(30 * time.Second)
+	lookup()
+	if atomic.LoadInt32(&lookups) != 1 {
+		t.Fatal(lookups)
+	}
+
+	*now = now.Add(time.Minute)
+	fail = true
+	if lookup() == nil || lookup() == nil || atomic.LoadInt32(&lookups) != 3 {
+		t.Fatal(lookups)
+	}
+
+	fail = false
+	lookup()
+	resolver.Flush()
+	lookup()
+	if atomic.LoadInt32(&lookups) != 5 {
+		t.Fatal(lookups)
+	}
+}
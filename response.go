@@ -97,3 +97,15 @@ func (r *Response) JSONUnmarshal(dest interface{}) error {
 
 	return r.WithUnmarshal(fn)
 }
+
+// StatusError
+//
+//	returned by helpers when the server responds with an unexpected status code
+type StatusError struct {
+	StatusCode int
+	Status     string
+}
+
+func (e *StatusError) Error() string {
+	return "unexpected response status: " + e.Status
+}
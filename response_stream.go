@@ -0,0 +1,216 @@
+package gopunch
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+)
+
+// StreamFormat
+//
+//	framing of a stream of JSON records
+type StreamFormat int
+
+const (
+	// StreamAuto picks the format from Content-Type, falling back to the first byte of the body
+	StreamAuto StreamFormat = iota
+	// StreamNDJSON is newline delimited JSON, one record per line
+	StreamNDJSON
+	// StreamJSONSeq is RFC 7464 JSON text sequences, records prefixed by a record separator
+	StreamJSONSeq
+	// StreamJSONArray is a single top-level JSON array
+	StreamJSONArray
+)
+
+// RecordErrorPolicy
+//
+//	what DecodeStream does with a record that cannot be decoded
+type RecordErrorPolicy int
+
+const (
+	AbortOnRecordError RecordErrorPolicy = iota
+	SkipOnRecordError
+)
+
+const recordSeparator = 0x1E
+
+// RecordError
+//
+//	returned by DecodeStream when a record cannot be decoded and the policy is abort
+type RecordError struct {
+	Index int
+	Err   error
+}
+
+func (e *RecordError) Error() string {
+	return fmt.Sprintf("record %d: %v", e.Index, e.Err)
+}
+
+func (e *RecordError) Unwrap() error {
+	return e.Err
+}
+
+type streamConfig struct {
+	format  StreamFormat
+	policy  RecordErrorPolicy
+	skipped func(err *RecordError)
+}
+
+// StreamOption
+//
+//	can be used to customize DecodeStream
+type StreamOption func(cfg *streamConfig)
+
+// WithStreamFormat
+//
+//	takes the framing of the body, StreamAuto by default
+func WithStreamFormat(format StreamFormat) StreamOption {
+	return func(cfg *streamConfig) {
+		cfg.format = format
+	}
+}
+
+// WithRecordErrorPolicy
+//
+//	takes what to do with undecodable records, AbortOnRecordError by default
+//	skipped, if not nil, is called for every record that was skipped
+func WithRecordErrorPolicy(policy RecordErrorPolicy, skipped func(err *RecordError)) StreamOption {
+	return func(cfg *streamConfig) {
+		cfg.policy = policy
+		cfg.skipped = skipped
+	}
+}
+
+// DecodeStream
+//
+//	decodes the body one record at a time and hands each to fn
+//	the next record is only read once fn returns, so a slow fn slows down the download
+//	an error returned by fn stops decoding and is returned as is
+func DecodeStream[T any](r *Response, fn func(T) error, opts ...StreamOption) error {
+	cfg := &streamConfig{}
+	for _, opt := range opts {
+		opt(cfg)
+	}
+
+	return r.WithUnmarshal(func(reader io.Reader) error {
+		buffered := bufio.NewReader(reader)
+
+		format := cfg.format
+		if format == StreamAuto {
+			format = detectStreamFormat(r, buffered)
+		}
+
+		handle := func(index int, raw []byte) error {
+			var record T
+			if err := json.Unmarshal(raw, &record); err != nil {
+				recordErr := &RecordError{Index: index, Err: err}
+				if cfg.policy == AbortOnRecordError {
+					return recordErr
+				}
+
+				if cfg.skipped != nil {
+					cfg.skipped(recordErr)
+				}
+
+				return nil
+			}
+
+			return fn(record)
+		}
+
+		switch format {
+		case StreamJSONArray:
+			return decodeJSONArray(buffered, handle)
+		case StreamJSONSeq:
+			return decodeDelimited(buffered, recordSeparator, handle)
+		}
+
+		return decodeDelimited(buffered, '\n', handle)
+	})
+}
+
+func detectStreamFormat(r *Response, reader *bufio.Reader) StreamFormat {
+	mediaType, _, _ := mime.ParseMediaType(r.httpResponse.Header.Get("Content-Type"))
+	switch mediaType {
+	case "application/x-ndjson", "application/ndjson", "application/jsonl", "application/x-jsonlines":
+		return StreamNDJSON
+	case "application/json-seq":
+		return StreamJSONSeq
+	}
+
+	for {
+		b, err := reader.ReadByte()
+		if err != nil {
+			return StreamNDJSON
+		}
+
+		switch b {
+		case ' ', '\t', '\r', '\n':
+			continue
+		case '[':
+			reader.UnreadByte()
+			return StreamJSONArray
+		case recordSeparator:
+			reader.UnreadByte()
+			return StreamJSONSeq
+		}
+
+		reader.UnreadByte()
+		return StreamNDJSON
+	}
+}
+
+func decodeDelimited(reader *bufio.Reader, delimiter byte, handle func(index int, raw []byte) error) error {
+	index := 0
+	for {
+		chunk, err := reader.ReadBytes(delimiter)
+		if err != nil && !errors.Is(err, io.EOF) {
+			return err
+		}
+
+		raw := bytes.TrimSpace(bytes.TrimSuffix(chunk, []byte{delimiter}))
+		if len(raw) > 0 {
+			if handleErr := handle(index, raw); handleErr != nil {
+				return handleErr
+			}
+			index++
+		}
+
+		if err != nil {
+			return nil
+		}
+	}
+}
+
+func decodeJSONArray(reader io.Reader, handle func(index int, raw []byte) error) error {
+	decoder := json.NewDecoder(reader)
+
+	token, err := decoder.Token()
+	if err != nil {
+		return err
+	}
+
+	if delim, ok := token.(json.Delim); !ok || delim != '[' {
+		return fmt.Errorf("expected top-level JSON array, got %v", token)
+	}
+
+	for index := 0; decoder.More(); index++ {
+		var raw json.RawMessage
+		if err := decoder.Decode(&raw); err != nil {
+			// the decoder cannot resync after a syntax error
+			return &RecordError{Index: index, Err: err}
+		}
+
+		if err := handle(index, raw); err != nil {
+			return err
+		}
+	}
+
+	_, err = decoder.Token()
+
+	return err
+}
@@ -0,0 +1,141 @@
+package gopunch
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+type streamRecord struct {
+	ID int `json:"id"`
+}
+
+var DecodeStreamTestCases = []struct {
+	Title       string
+	ContentType string
+	Body        string
+	Options     []StreamOption
+	ExpectedIDs []int
+	Skipped     int
+	ExpectedErr bool
+}{
+	{
+		Title:       "Given NDJSON body; every line should be decoded in order",
+		ContentType: "application/x-ndjson",
+		Body:        "{\"id\":1}\n{\"id\":2}\r\n\n{\"id\":3}",
+		ExpectedIDs: []int{1, 2, 3},
+	},
+	{
+		Title:       "Given JSON text sequence body; every record should be decoded in order",
+		ContentType: "application/json-seq",
+		Body:        "\x1e{\"id\":1}\n\x1e{\"id\":2}\n",
+		ExpectedIDs: []int{1, 2},
+	},
+	{
+		Title:       "Given top-level JSON array with application/json; format should be detected and elements decoded",
+		ContentType: "application/json",
+		Body:        ` [{"id":1},{"id":2},{"id":3}]`,
+		ExpectedIDs: []int{1, 2, 3},
+	},
+	{
+		Title:       "Given bad NDJSON line and skip policy; bad line should be skipped and reported",
+		ContentType: "application/x-ndjson",
+		Body:        "{\"id\":1}\nnot json\n{\"id\":3}\n",
+		Options:     []StreamOption{WithRecordErrorPolicy(SkipOnRecordError, nil)},
+		ExpectedIDs: []int{1, 3},
+		Skipped:     1,
+	},
+	{
+		Title:       "Given wrongly typed array element and skip policy; element should be skipped",
+		ContentType: "application/json",
+		Body:        `[{"id":1},{"id":"two"},{"id":3}]`,
+		Options:     []StreamOption{WithRecordErrorPolicy(SkipOnRecordError, nil)},
+		ExpectedIDs: []int{1, 3},
+		Skipped:     1,
+	},
+	{
+		Title:       "Given bad NDJSON line and default abort policy; decoding should stop with RecordError",
+		ContentType: "application/x-ndjson",
+		Body:        "{\"id\":1}\nnot json\n{\"id\":3}\n",
+		ExpectedIDs: []int{1},
+		ExpectedErr: true,
+	},
+}
+
+func Test_DecodeStream(t *testing.T) {
+	for _, testCase := range DecodeStreamTestCases {
+		t.Log(testCase.Title)
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", testCase.ContentType)
+			w.Write([]byte(testCase.Body))
+		}))
+
+		resp := New(server.URL).Get(context.Background(), "/export")
+
+		skipped := 0
+		opts := append([]StreamOption{}, testCase.Options...)
+		if testCase.Skipped > 0 {
+			opts = append(opts, WithRecordErrorPolicy(SkipOnRecordError, func(*RecordError) { skipped++ }))
+		}
+
+		var ids []int
+		err := DecodeStream(resp, func(record streamRecord) error {
+			ids = append(ids, record.ID)
+			return nil
+		}, opts...)
+		resp.Close()
+		server.Close()
+
+		if testCase.ExpectedErr {
+			var recordErr *RecordError
+			if !errors.As(err, &recordErr) || recordErr.Index != 1 {
+				t.Fail()
+			}
+		} else if err != nil {
+			t.Fatal(err)
+		}
+
+		if len(ids) != len(testCase.ExpectedIDs) {
+			t.Fatalf("expected %v, got %v", testCase.ExpectedIDs, ids)
+		}
+
+		for i := range ids {
+			if ids[i] != testCase.ExpectedIDs[i] {
+				t.Fail()
+			}
+		}
+
+		if skipped != testCase.Skipped {
+			t.Fail()
+		}
+	}
+}
+
+func Test_DecodeStream_CallbackError(t *testing.T) {
+	t.Log("Given callback returning error; decoding should stop and return that error")
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("{\"id\":1}\n{\"id\":2}\n"))
+	}))
+	defer server.Close()
+
+	resp := New(server.URL).Get(context.Background(), "/export")
+	defer resp.Close()
+
+	stop := errors.New("stop")
+	calls := 0
+	err := DecodeStream(resp, func(streamRecord) error {
+		calls++
+		return stop
+	}, WithStreamFormat(StreamNDJSON))
+
+	if !errors.Is(err, stop) || calls != 1 {
+		t.Fail()
+	}
+
+	t.Log("Given response with error; DecodeStream should return it")
+	if err := DecodeStream(NewResponse(nil, ErrHttpResponseNil), func(streamRecord) error { return nil }); !errors.Is(err, ErrHttpResponseNil) {
+		t.Fail()
+	}
+}
@@ -0,0 +1,155 @@
+package gopunch
+
+import (
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// RetryPolicy
+//
+//	describes how failed requests are retried
+//	only idempotent methods are retried, POST and PATCH too when
+//	the request carries an Idempotency-Key header
+type RetryPolicy struct {
+	// MaxAttempts is the total number of attempts, including the first one
+	MaxAttempts int
+	// Backoff is the wait before the first retry, doubled after every attempt
+	Backoff time.Duration
+	// MaxBackoff caps the wait between attempts, Retry-After included, zero means no cap
+	MaxBackoff time.Duration
+}
+
+var retryableStatusCodes = map[int]bool{
+	http.StatusTooManyRequests:    true,
+	http.StatusBadGateway:         true,
+	http.StatusServiceUnavailable: true,
+	http.StatusGatewayTimeout:     true,
+}
+
+func isRetryableRequest(req *http.Request) bool {
+	switch req.Method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodTrace, http.MethodPut, http.MethodDelete:
+		return true
+	case http.MethodPost, http.MethodPatch:
+		return req.Header.Get(IdempotencyKeyHeader) != ""
+	}
+
+	return false
+}
+
+func (p *RetryPolicy) wait(attempt int, resp *http.Response) time.Duration {
+	if resp != nil {
+		if wait, ok := retryAfter(resp.Header.Get("Retry-After")); ok {
+			if p.MaxBackoff > 0 && wait > p.MaxBackoff {
+				return p.MaxBackoff
+			}
+			return wait
+		}
+	}
+
+	wait := p.Backoff
+	for i := 1; i < attempt; i++ {
+		wait *= 2
+		if p.MaxBackoff > 0 && wait > p.MaxBackoff {
+			return p.MaxBackoff
+		}
+	}
+
+	return wait
+}
+
+// retryAfter parses Retry-After, either delay seconds or an HTTP date
+func retryAfter(value string) (time.Duration, bool) {
+	if value == "" {
+		return 0, false
+	}
+
+	if seconds, err := strconv.Atoi(value); err == nil {
+		if seconds < 0 {
+			return 0, false
+		}
+		return time.Duration(seconds) * time.Second, true
+	}
+
+	date, err := http.ParseTime(value)
+	if err != nil {
+		return 0, false
+	}
+
+	wait := date.Sub(nowFunc())
+	if wait < 0 {
+		wait = 0
+	}
+
+	return wait, true
+}
+
+func (c *Client) send(req *http.Request) (*http.Response, error) {
+	if timeouts := requestTimeoutsOf(req.Context()); timeouts != nil && !timeouts.deadline.IsZero() {
+		return c.sendBefore(req, timeouts)
+	}
+
+	return c.retry(req)
+}
+
+// retry sends req, retrying it as the retry policy allows
+func (c *Client) retry(req *http.Request) (*http.Response, error) {
+	policy := c.retryPolicy
+	if policy == nil || policy.MaxAttempts < 2 || !isRetryableRequest(req) || !canReplay(req) {
+		return c.attempt(req)
+	}
+
+	attemptReq := req
+	for attempt := 1; ; attempt++ {
+		resp, err := c.attempt(attemptReq)
+		if attempt >= policy.MaxAttempts || req.Context().Err() != nil {
+			return resp, err
+		}
+
+		if err == nil && !retryableStatusCodes[resp.StatusCode] {
+			return resp, nil
+		}
+
+		timer := time.NewTimer(policy.wait(attempt, resp))
+		if err == nil {
+			discard(resp)
+		}
+
+		select {
+		case <-req.Context().Done():
+			timer.Stop()
+			return nil, req.Context().Err()
+		case <-timer.C:
+		}
+
+		if attemptReq, err = replayRequest(req); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// canReplay reports whether the request body can be sent more than once
+func canReplay(req *http.Request) bool {
+	return req.Body == nil || req.Body == http.NoBody || req.GetBody != nil
+}
+
+// replayRequest returns a copy of req with a fresh body
+func replayRequest(req *http.Request) (*http.Request, error) {
+	replay := req.Clone(req.Context())
+	if req.GetBody != nil {
+		body, err := req.GetBody()
+		if err != nil {
+			return nil, err
+		}
+		replay.Body = body
+	}
+
+	return replay, nil
+}
+
+func discard(resp *http.Response) {
+	io.Copy(io.Discard, resp.Body)
+	resp.Body.Close()
+}
@@ -0,0 +1,156 @@
+package gopunch
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+type attemptRecorder struct {
+	mu       sync.Mutex
+	keys     []string
+	bodies   []string
+	failures int
+}
+
+func (a *attemptRecorder) handler(w http.ResponseWriter, r *http.Request) {
+	body, _ := io.ReadAll(r.Body)
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	a.keys = append(a.keys, r.Header.Get(IdempotencyKeyHeader))
+	a.bodies = append(a.bodies, string(body))
+	if a.failures > 0 {
+		a.failures--
+		w.WriteHeader(http.StatusServiceUnavailable)
+		return
+	}
+
+	w.WriteHeader(http.StatusCreated)
+}
+
+var RetryTestCases = []struct {
+	Title            string
+	Method           string
+	Options          []Option
+	Failures         int
+	ExpectedAttempts int
+	ExpectedStatus   int
+}{
+	{
+		Title:            "Given GET failing twice; request should be retried until it succeeds",
+		Method:           http.MethodGet,
+		Failures:         2,
+		ExpectedAttempts: 3,
+		ExpectedStatus:   http.StatusCreated,
+	},
+	{
+		Title:            "Given POST without Idempotency-Key; request should not be retried",
+		Method:           http.MethodPost,
+		Failures:         2,
+		ExpectedAttempts: 1,
+		ExpectedStatus:   http.StatusServiceUnavailable,
+	},
+	{
+		Title:            "Given POST with Idempotency-Key; request should be retried with the same key and body",
+		Method:           http.MethodPost,
+		Options:          []Option{WithNewIdempotencyKey()},
+		Failures:         2,
+		ExpectedAttempts: 3,
+		ExpectedStatus:   http.StatusCreated,
+	},
+	{
+		Title:            "Given PATCH failing more than max attempts; last response should be returned",
+		Method:           http.MethodPatch,
+		Options:          []Option{WithIdempotencyKey("fixed")},
+		Failures:         5,
+		ExpectedAttempts: 3,
+		ExpectedStatus:   http.StatusServiceUnavailable,
+	},
+}
+
+func Test_RetryPolicy(t *testing.T) {
+	for _, testCase := range RetryTestCases {
+		t.Log(testCase.Title)
+		recorder := &attemptRecorder{failures: testCase.Failures}
+		server := httptest.NewServer(http.HandlerFunc(recorder.handler))
+
+		client := New(server.URL)
+		client.SetRetryPolicy(&RetryPolicy{MaxAttempts: 3, Backoff: time.Millisecond})
+
+		resp := client.Custom(context.Background(), testCase.Method, "/payments", []byte("payload"), testCase.Options...)
+		if resp.Err() != nil {
+			t.Fatal(resp.Err())
+		}
+		status := resp.HttpResponse().StatusCode
+		resp.Close()
+		server.Close()
+
+		if status != testCase.ExpectedStatus {
+			t.Logf("expected status %d, got %d", testCase.ExpectedStatus, status)
+			t.Fail()
+		}
+
+		if len(recorder.keys) != testCase.ExpectedAttempts {
+			t.Logf("expected %d attempts, got %d", testCase.ExpectedAttempts, len(recorder.keys))
+			t.Fail()
+		}
+
+		for i := range recorder.keys {
+			if recorder.keys[i] != recorder.keys[0] || recorder.bodies[i] != "payload" {
+				t.Log("key or body changed between attempts")
+				t.Fail()
+			}
+		}
+	}
+}
+
+func Test_RetryPolicy_ContextCancel(t *testing.T) {
+	t.Log("Given context cancelled while waiting between attempts; request should stop with context error")
+	recorder := &attemptRecorder{failures: 10}
+	server := httptest.NewServer(http.HandlerFunc(recorder.handler))
+	defer server.Close()
+
+	client := New(server.URL)
+	client.SetRetryPolicy(&RetryPolicy{MaxAttempts: 5, Backoff: time.Hour})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	resp := client.Get(ctx, "/slow")
+	if resp.Err() != context.DeadlineExceeded {
+		t.Fail()
+	}
+}
+
+var RetryAfterTestCases = []struct {
+	Title      string
+	RetryAfter string
+	MaxBackoff time.Duration
+	Expected   time.Duration
+}{
+	{Title: "Given Retry-After seconds; it should be waited", RetryAfter: "3", Expected: 3 * time.Second},
+	{Title: "Given Retry-After over MaxBackoff; wait should be capped", RetryAfter: "86400", MaxBackoff: time.Minute, Expected: time.Minute},
+	{Title: "Given Retry-After HTTP date; wait should last until then", RetryAfter: "Tue, 14 Nov 2023 22:13:40 GMT", Expected: 20 * time.Second},
+	{Title: "Given Retry-After date in the past; retry should not wait", RetryAfter: "Tue, 14 Nov 2023 22:13:00 GMT", Expected: 0},
+	{Title: "Given invalid Retry-After; backoff should be used", RetryAfter: "soon", Expected: 100 * time.Millisecond},
+}
+
+func Test_RetryPolicy_RetryAfter(t *testing.T) {
+	withFrozenClock(t, time.Unix(1700000000, 0))
+
+	for _, testCase := range RetryAfterTestCases {
+		t.Log(testCase.Title)
+		policy := &RetryPolicy{MaxAttempts: 3, Backoff: 100 * time.Millisecond, MaxBackoff: testCase.MaxBackoff}
+		resp := &http.Response{Header: http.Header{"Retry-After": {testCase.RetryAfter}}}
+
+		if wait := policy.wait(1, resp); wait != testCase.Expected {
+			t.Fatal(wait)
+		}
+	}
+}
@@ -0,0 +1,452 @@
+package gopunch
+
+import (
+	"encoding/json"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/cookiejar"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+var ErrIllegalCookieDomain = errors.New("cookie domain is a public suffix or does not match the host")
+
+type sessionCookie struct {
+	Name     string        `json:"name"`
+	Value    string        `json:"value"`
+	Domain   string        `json:"domain"`
+	Path     string        `json:"path"`
+	HostOnly bool          `json:"host_only"`
+	Secure   bool          `json:"secure"`
+	HttpOnly bool          `json:"http_only"`
+	SameSite http.SameSite `json:"same_site,omitempty"`
+	// Expires is zero for session cookies
+	Expires time.Time `json:"expires,omitempty"`
+	Created time.Time `json:"created"`
+}
+
+func (c *sessionCookie) key() string {
+	return c.Domain + ";" + c.Path + ";" + c.Name
+}
+
+func (c *sessionCookie) expired(now time.Time) bool {
+	return !c.Expires.IsZero() && !now.Before(c.Expires)
+}
+
+func (c *sessionCookie) cookie() *http.Cookie {
+	return &http.Cookie{
+		Name:     c.Name,
+		Value:    c.Value,
+		Domain:   c.Domain,
+		Path:     c.Path,
+		Expires:  c.Expires,
+		Secure:   c.Secure,
+		HttpOnly: c.HttpOnly,
+		SameSite: c.SameSite,
+	}
+}
+
+// SessionJar
+//
+//	in-memory http.CookieJar following RFC 6265, cookies can be listed, added and cleared per domain
+//	optionally persisted to a JSON file so sessions survive restarts
+//	without a public suffix list every cookie is host-only, see NewSessionJar
+//	safe for concurrent use
+type SessionJar struct {
+	mu      sync.Mutex
+	psl     cookiejar.PublicSuffixList
+	file    string
+	cookies map[string]*sessionCookie
+	version uint64
+
+	// saveMu orders file writes, which happen outside mu so requests do not wait on the disk
+	saveMu  sync.Mutex
+	written uint64
+}
+
+// NewSessionJar
+//
+//	takes a public suffix list such as golang.org/x/net/publicsuffix.List
+//	when nil, the Domain attribute is ignored and every cookie is only sent back to the host that set it,
+//	since without the list a cookie for "example.co.uk" cannot be told apart from one for all of "co.uk"
+//	returns *SessionJar
+func NewSessionJar(psl cookiejar.PublicSuffixList) *SessionJar {
+	return &SessionJar{
+		psl:     psl,
+		cookies: map[string]*sessionCookie{},
+	}
+}
+
+// OpenSessionJar
+//
+//	takes the path of a JSON file and a public suffix list, see NewSessionJar about passing nil
+//	loads the cookies stored in the file if it exists, and saves every change back to it
+//	session cookies are saved too, so a login is kept across runs
+//	returns *SessionJar
+func OpenSessionJar(file string, psl cookiejar.PublicSuffixList) (*SessionJar, error) {
+	jar := NewSessionJar(psl)
+	jar.file = file
+
+	data, err := os.ReadFile(file)
+	if errors.Is(err, os.ErrNotExist) {
+		return jar, nil
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	var cookies []*sessionCookie
+	if err := json.Unmarshal(data, &cookies); err != nil {
+		return nil, err
+	}
+
+	now := nowFunc()
+	for _, cookie := range cookies {
+		if !cookie.expired(now) {
+			jar.cookies[cookie.key()] = cookie
+		}
+	}
+
+	return jar, nil
+}
+
+// Save
+//
+//	writes the cookies to the file given to OpenSessionJar, does nothing for in-memory jars
+func (j *SessionJar) Save() error {
+	return j.update(func() bool { return true })
+}
+
+// update applies change under the lock and, if it changed anything, saves the cookies once the lock is released
+func (j *SessionJar) update(change func() bool) error {
+	j.mu.Lock()
+	if !change() || j.file == "" {
+		j.mu.Unlock()
+		return nil
+	}
+
+	j.version++
+	version := j.version
+	data, err := j.snapshot()
+	j.mu.Unlock()
+
+	if err != nil {
+		return err
+	}
+
+	return j.write(data, version)
+}
+
+// snapshot encodes the live cookies, it must be called with mu held
+func (j *SessionJar) snapshot() ([]byte, error) {
+	now := nowFunc()
+	cookies := make([]*sessionCookie, 0, len(j.cookies))
+	for _, cookie := range j.cookies {
+		if !cookie.expired(now) {
+			cookies = append(cookies, cookie)
+		}
+	}
+
+	sort.Slice(cookies, func(a, b int) bool {
+		return cookies[a].key() < cookies[b].key()
+	})
+
+	return json.MarshalIndent(cookies, "", "  ")
+}
+
+// write stores a snapshot unless a newer one was already written
+func (j *SessionJar) write(data []byte, version uint64) error {
+	j.saveMu.Lock()
+	defer j.saveMu.Unlock()
+
+	if version <= j.written {
+		return nil
+	}
+
+	// write then rename so a crash never leaves a truncated file behind
+	tmp, err := os.CreateTemp(filepath.Dir(j.file), ".session-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+
+	if err := os.Rename(tmp.Name(), j.file); err != nil {
+		return err
+	}
+
+	j.written = version
+
+	return nil
+}
+
+// SetCookies
+//
+//	implements http.CookieJar, stores the cookies of a response from u
+//	cookies with an illegal domain are ignored
+func (j *SessionJar) SetCookies(u *url.URL, cookies []*http.Cookie) {
+	j.setCookies(u, cookies)
+}
+
+func (j *SessionJar) setCookies(u *url.URL, cookies []*http.Cookie) error {
+	if u.Scheme != "http" && u.Scheme != "https" && u.Scheme != "ws" && u.Scheme != "wss" {
+		return nil
+	}
+
+	host := canonicalCookieHost(u.Host)
+	if host == "" {
+		return nil
+	}
+
+	var illegal error
+	err := j.update(func() bool {
+		now := nowFunc()
+		changed := false
+		for _, cookie := range cookies {
+			entry, err := j.newEntry(u, host, cookie, now)
+			if err != nil {
+				illegal = err
+				continue
+			}
+
+			previous, ok := j.cookies[entry.key()]
+			if ok {
+				entry.Created = previous.Created
+			}
+
+			if entry.expired(now) {
+				if ok {
+					delete(j.cookies, entry.key())
+					changed = true
+				}
+				continue
+			}
+
+			if !ok || *previous != *entry {
+				j.cookies[entry.key()] = entry
+				changed = true
+			}
+		}
+
+		return changed
+	})
+	if err != nil {
+		return err
+	}
+
+	return illegal
+}
+
+func (j *SessionJar) newEntry(u *url.URL, host string, cookie *http.Cookie, now time.Time) (*sessionCookie, error) {
+	entry := &sessionCookie{
+		Name:     cookie.Name,
+		Value:    cookie.Value,
+		Secure:   cookie.Secure,
+		HttpOnly: cookie.HttpOnly,
+		SameSite: cookie.SameSite,
+		Created:  now,
+	}
+
+	domain := strings.ToLower(strings.TrimPrefix(cookie.Domain, "."))
+	if j.psl == nil {
+		// no way to tell a registrable domain from a public suffix, keep the cookie host-only
+		domain = ""
+	}
+
+	switch {
+	case domain == "" || domain == host:
+		entry.Domain, entry.HostOnly = host, domain == ""
+	case net.ParseIP(host) != nil, !strings.HasSuffix(host, "."+domain), j.psl.PublicSuffix(domain) == domain:
+		return nil, ErrIllegalCookieDomain
+	default:
+		entry.Domain = domain
+	}
+
+	entry.Path = cookie.Path
+	if entry.Path == "" || entry.Path[0] != '/' {
+		entry.Path = defaultCookiePath(u.Path)
+	}
+
+	switch {
+	case cookie.MaxAge < 0:
+		entry.Expires = now
+	case cookie.MaxAge > 0:
+		entry.Expires = now.Add(time.Duration(cookie.MaxAge) * time.Second)
+	case !cookie.Expires.IsZero():
+		entry.Expires = cookie.Expires
+	}
+
+	return entry, nil
+}
+
+// Cookies
+//
+//	implements http.CookieJar, returns the cookies to send to u
+//	longer paths come first, then older cookies, then by name
+func (j *SessionJar) Cookies(u *url.URL) []*http.Cookie {
+	host := canonicalCookieHost(u.Host)
+	secure := u.Scheme == "https" || u.Scheme == "wss"
+	path := u.Path
+	if path == "" {
+		path = "/"
+	}
+
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	now := nowFunc()
+	var matches []*sessionCookie
+	for key, entry := range j.cookies {
+		if entry.expired(now) {
+			delete(j.cookies, key)
+			continue
+		}
+
+		if (entry.Secure && !secure) || !entry.domainMatch(host) || !cookiePathMatch(entry.Path, path) {
+			continue
+		}
+
+		matches = append(matches, entry)
+	}
+
+	sort.Slice(matches, func(a, b int) bool {
+		if len(matches[a].Path) != len(matches[b].Path) {
+			return len(matches[a].Path) > len(matches[b].Path)
+		}
+
+		if !matches[a].Created.Equal(matches[b].Created) {
+			return matches[a].Created.Before(matches[b].Created)
+		}
+
+		return matches[a].Name < matches[b].Name
+	})
+
+	cookies := make([]*http.Cookie, 0, len(matches))
+	for _, entry := range matches {
+		cookies = append(cookies, &http.Cookie{Name: entry.Name, Value: entry.Value})
+	}
+
+	return cookies
+}
+
+// DomainCookies
+//
+//	returns every cookie stored for domain and its subdomains, with domain, path and expiry filled in
+func (j *SessionJar) DomainCookies(domain string) []*http.Cookie {
+	domain = canonicalCookieHost(domain)
+
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	now := nowFunc()
+	var cookies []*http.Cookie
+	for _, entry := range j.cookies {
+		if !entry.expired(now) && withinDomain(entry.Domain, domain) {
+			cookies = append(cookies, entry.cookie())
+		}
+	}
+
+	sort.Slice(cookies, func(a, b int) bool {
+		if cookies[a].Domain != cookies[b].Domain {
+			return cookies[a].Domain < cookies[b].Domain
+		}
+
+		return cookies[a].Name < cookies[b].Name
+	})
+
+	return cookies
+}
+
+// AddCookies
+//
+//	takes a domain and cookies, stores them as if https://domain had set them
+//	a cookie without Domain, or any cookie when the jar has no public suffix list, is only sent back to that exact host
+//	returns ErrIllegalCookieDomain if a cookie's Domain does not cover domain or is a public suffix
+func (j *SessionJar) AddCookies(domain string, cookies ...*http.Cookie) error {
+	return j.setCookies(&url.URL{Scheme: "https", Host: domain, Path: "/"}, cookies)
+}
+
+// Clear
+//
+//	removes every cookie stored for domain and its subdomains
+func (j *SessionJar) Clear(domain string) error {
+	domain = canonicalCookieHost(domain)
+
+	return j.update(func() bool {
+		changed := false
+		for key, entry := range j.cookies {
+			if withinDomain(entry.Domain, domain) {
+				delete(j.cookies, key)
+				changed = true
+			}
+		}
+
+		return changed
+	})
+}
+
+// ClearAll
+//
+//	removes every cookie
+func (j *SessionJar) ClearAll() error {
+	return j.update(func() bool {
+		j.cookies = map[string]*sessionCookie{}
+		return true
+	})
+}
+
+func (c *sessionCookie) domainMatch(host string) bool {
+	if c.HostOnly {
+		return host == c.Domain
+	}
+
+	return withinDomain(host, c.Domain)
+}
+
+// withinDomain reports whether host is domain or one of its subdomains
+func withinDomain(host, domain string) bool {
+	return host == domain || strings.HasSuffix(host, "."+domain)
+}
+
+func canonicalCookieHost(host string) string {
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+
+	host = strings.TrimPrefix(strings.TrimSuffix(strings.Trim(host, "[]"), "."), ".")
+
+	return strings.ToLower(host)
+}
+
+// defaultCookiePath is the directory of the request path, RFC 6265 section 5.1.4
+func defaultCookiePath(path string) string {
+	i := strings.LastIndex(path, "/")
+	if i <= 0 {
+		return "/"
+	}
+
+	return path[:i]
+}
+
+func cookiePathMatch(cookiePath, path string) bool {
+	if !strings.HasPrefix(path, cookiePath) {
+		return false
+	}
+
+	return len(path) == len(cookiePath) || strings.HasSuffix(cookiePath, "/") || path[len(cookiePath)] == '/'
+}
@@ -0,0 +1,238 @@
+package gopunch
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// suffixList treats "com" and "co.uk" as public suffixes
+type suffixList struct{}
+
+func (suffixList) PublicSuffix(domain string) string {
+	if strings.HasSuffix(domain, ".co.uk") || domain == "co.uk" {
+		return "co.uk"
+	}
+
+	return domain[strings.LastIndex(domain, ".")+1:]
+}
+
+func (suffixList) String() string {
+	return "test"
+}
+
+func Test_Session_Login(t *testing.T) {
+	t.Log("Given session mode; cookie set by login should be sent on later calls")
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/login":
+			http.SetCookie(w, &http.Cookie{Name: "sid", Value: "abc", Path: "/", HttpOnly: true})
+		case "/me":
+			if cookie, err := r.Cookie("sid"); err != nil || cookie.Value != "abc" {
+				w.WriteHeader(http.StatusUnauthorized)
+			}
+		}
+	}))
+	defer server.Close()
+
+	client := New(server.URL)
+	if client.Session() != nil {
+		t.Fatal("session should be disabled by default")
+	}
+
+	client.SetSession(NewSessionJar(nil))
+	for _, endPoint := range []string{"/login", "/me"} {
+		resp := client.Get(context.Background(), endPoint)
+		if resp.Err() != nil {
+			t.Fatal(resp.Err())
+		}
+		resp.Close()
+
+		if resp.HttpResponse().StatusCode != http.StatusOK {
+			t.Fatal(endPoint, resp.HttpResponse().Status)
+		}
+	}
+
+	u, _ := url.Parse(server.URL)
+	cookies := client.Session().DomainCookies(u.Hostname())
+	if len(cookies) != 1 || cookies[0].Name != "sid" || !cookies[0].HttpOnly {
+		t.Fatal(cookies)
+	}
+
+	client.SetSession(nil)
+	if client.Session() != nil || client.HttpClient().Jar != nil {
+		t.Fatal("session should be disabled")
+	}
+}
+
+var SessionDomainTestCases = []struct {
+	Title   string
+	Host    string
+	Domain  string
+	Illegal bool
+}{
+	{Title: "Given host only cookie; it should be stored", Host: "www.example.co.uk"},
+	{Title: "Given parent domain; it should be stored", Host: "www.example.co.uk", Domain: ".example.co.uk"},
+	{Title: "Given public suffix domain; it should be rejected", Host: "www.example.co.uk", Domain: "co.uk", Illegal: true},
+	{Title: "Given unrelated domain; it should be rejected", Host: "www.example.co.uk", Domain: "other.com", Illegal: true},
+	{Title: "Given domain on an ip host; it should be rejected", Host: "127.0.0.1", Domain: "0.0.1", Illegal: true},
+}
+
+func Test_SessionJar_Domains(t *testing.T) {
+	for _, testCase := range SessionDomainTestCases {
+		t.Log(testCase.Title)
+		jar := NewSessionJar(suffixList{})
+		err := jar.AddCookies(testCase.Host, &http.Cookie{Name: "a", Value: "1", Domain: testCase.Domain})
+		if (err == ErrIllegalCookieDomain) != testCase.Illegal {
+			t.Fatal(err)
+		}
+
+		stored := len(jar.DomainCookies(testCase.Host)) + len(jar.DomainCookies(testCase.Domain))
+		if testCase.Illegal == (stored > 0) {
+			t.Fatal(stored)
+		}
+	}
+}
+
+func Test_SessionJar_NoSuffixList(t *testing.T) {
+	jar := NewSessionJar(nil)
+
+	t.Log("Given no public suffix list and a cookie for co.uk; it should be kept host-only")
+	if err := jar.AddCookies("evil.co.uk", &http.Cookie{Name: "a", Value: "1", Domain: "co.uk"}); err != nil {
+		t.Fatal(err)
+	}
+
+	t.Log("Given no public suffix list and a cookie for the parent domain; it should be kept host-only")
+	jar.AddCookies("www.example.com", &http.Cookie{Name: "b", Value: "2", Domain: "example.com"})
+
+	for _, rawURL := range []string{"https://victim.co.uk/", "https://api.example.com/"} {
+		u, _ := url.Parse(rawURL)
+		if cookies := jar.Cookies(u); len(cookies) != 0 {
+			t.Fatal(rawURL, cookies)
+		}
+	}
+
+	u, _ := url.Parse("https://evil.co.uk/")
+	if cookies := jar.Cookies(u); len(cookies) != 1 || cookies[0].Name != "a" {
+		t.Fatal(cookies)
+	}
+}
+
+func Test_SessionJar_Matching(t *testing.T) {
+	now := withFrozenClock(t, time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+	jar := NewSessionJar(suffixList{})
+	jar.AddCookies("api.example.com",
+		&http.Cookie{Name: "host", Value: "1"},
+		&http.Cookie{Name: "shared", Value: "2", Domain: "example.com"},
+		&http.Cookie{Name: "secure", Value: "3", Secure: true},
+		&http.Cookie{Name: "deep", Value: "4", Path: "/v1/items"},
+		&http.Cookie{Name: "short", Value: "5", MaxAge: 60},
+	)
+
+	names := func(rawURL string) string {
+		u, _ := url.Parse(rawURL)
+		var names []string
+		for _, cookie := range jar.Cookies(u) {
+			names = append(names, cookie.Name)
+		}
+		return strings.Join(names, ",")
+	}
+
+	t.Log("Given https request to the path; longest path should come first and every cookie should match")
+	if got := names("https://api.example.com/v1/items/7"); got != "deep,host,secure,shared,short" {
+		t.Fatal(got)
+	}
+
+	t.Log("Given plain http request to a sibling subdomain; only the shared, non secure cookie should match")
+	if got := names("http://www.example.com/v1/itemsx"); got != "shared" {
+		t.Fatal(got)
+	}
+
+	t.Log("Given max age elapsed; cookie should no longer be sent")
+	*now = now.Add(time.Minute)
+	if got := names("http://api.example.com/"); got != "host,shared" {
+		t.Fatal(got)
+	}
+
+	t.Log("Given cleared subdomain; only cookies of the parent domain should remain")
+	jar.Clear("api.example.com")
+	if got := names("https://api.example.com/v1/items"); got != "shared" {
+		t.Fatal(got)
+	}
+
+	jar.ClearAll()
+	if got := names("https://api.example.com/"); got != "" {
+		t.Fatal(got)
+	}
+}
+
+func Test_SessionJar_Persistence(t *testing.T) {
+	t.Log("Given file backed jar; cookies should be available after reopening it, expired ones dropped")
+	now := withFrozenClock(t, time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+	file := filepath.Join(t.TempDir(), "session.json")
+
+	jar, err := OpenSessionJar(file, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	jar.AddCookies("example.com",
+		&http.Cookie{Name: "sid", Value: "abc"},
+		&http.Cookie{Name: "remember", Value: "yes", Expires: now.Add(time.Hour)},
+		&http.Cookie{Name: "flash", Value: "hi", MaxAge: 10},
+	)
+
+	*now = now.Add(time.Minute)
+	reopened, err := OpenSessionJar(file, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cookies := reopened.Cookies(&url.URL{Scheme: "https", Host: "example.com", Path: "/"})
+	if len(cookies) != 2 || cookies[0].Name != "remember" || cookies[1].Name != "sid" {
+		t.Fatal(cookies)
+	}
+
+	reopened.Clear("example.com")
+	reopened, _ = OpenSessionJar(file, nil)
+	if len(reopened.DomainCookies("example.com")) != 0 {
+		t.Fatal("cleared cookies should not be persisted")
+	}
+}
+
+func Test_SessionJar_ConcurrentPersistence(t *testing.T) {
+	t.Log("Given concurrent responses setting cookies; the file should end up with every cookie")
+	file := filepath.Join(t.TempDir(), "cookies.json")
+	jar, err := OpenSessionJar(file, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	u, _ := url.Parse("https://example.com/")
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			jar.SetCookies(u, []*http.Cookie{{Name: fmt.Sprintf("c%02d", i), Value: "v"}})
+			jar.Cookies(u)
+		}(i)
+	}
+	wg.Wait()
+
+	reopened, err := OpenSessionJar(file, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if cookies := reopened.Cookies(u); len(cookies) != 20 {
+		t.Fatal(len(cookies))
+	}
+}
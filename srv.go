@@ -0,0 +1,166 @@
+package gopunch
+
+import (
+	"context"
+	"errors"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	ErrInvalidSRVName = errors.New("srv name must look like _service._proto.name")
+	ErrInvalidSRVTTL  = errors.New("srv ttl must be positive")
+)
+
+// SRVLookupFunc
+//
+//	looks up SRV records, net.DefaultResolver.LookupSRV satisfies it
+type SRVLookupFunc func(ctx context.Context, service, proto, name string) (string, []*net.SRV, error)
+
+// SRVDiscovery
+//
+//	turns an SRV name such as "_api._tcp.example.com" into weighted base urls
+//	only the targets of the lowest priority are used, SRV weights become Endpoint weights
+//	answers are kept for the TTL, a failed refresh keeps the previous endpoints
+//	safe for concurrent use
+type SRVDiscovery struct {
+	service string
+	proto   string
+	name    string
+	scheme  string
+	ttl     time.Duration
+	lookup  SRVLookupFunc
+
+	mu        sync.Mutex
+	endpoints []Endpoint
+	expires   time.Time
+}
+
+// NewSRVDiscovery
+//
+//	takes the SRV name, the scheme of the base urls, such as "https", and how long answers are kept
+//	returns *SRVDiscovery using net.DefaultResolver, error if the name is not _service._proto.name or ttl is not positive
+func NewSRVDiscovery(srvName, scheme string, ttl time.Duration) (*SRVDiscovery, error) {
+	if ttl <= 0 {
+		return nil, ErrInvalidSRVTTL
+	}
+
+	labels := strings.SplitN(strings.TrimSuffix(srvName, "."), ".", 3)
+	if len(labels) != 3 || len(labels[0]) < 2 || len(labels[1]) < 2 || labels[0][0] != '_' || labels[1][0] != '_' || labels[2] == "" {
+		return nil, ErrInvalidSRVName
+	}
+
+	return &SRVDiscovery{
+		service: labels[0][1:],
+		proto:   labels[1][1:],
+		name:    labels[2],
+		scheme:  scheme,
+		ttl:     ttl,
+		lookup:  net.DefaultResolver.LookupSRV,
+	}, nil
+}
+
+// SetLookup
+//
+//	sets the function looking up SRV records, for example a fake one in tests
+func (d *SRVDiscovery) SetLookup(lookup SRVLookupFunc) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	d.lookup = lookup
+	d.expires = time.Time{}
+}
+
+// Endpoints
+//
+//	returns the cached endpoints, looking them up once they expired
+//	the previous endpoints are returned along with the error of a failed refresh
+func (d *SRVDiscovery) Endpoints(ctx context.Context) ([]Endpoint, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if d.endpoints != nil && nowFunc().Before(d.expires) {
+		return d.endpoints, nil
+	}
+
+	_, records, err := d.lookup(ctx, d.service, d.proto, d.name)
+	if err == nil && len(records) == 0 {
+		err = &net.DNSError{Err: ErrNoAddresses.Error(), Name: d.name}
+	}
+
+	if err != nil {
+		return d.endpoints, err
+	}
+
+	d.endpoints = d.toEndpoints(records)
+	d.expires = nowFunc().Add(d.ttl)
+
+	return d.endpoints, nil
+}
+
+func (d *SRVDiscovery) toEndpoints(records []*net.SRV) []Endpoint {
+	priority := records[0].Priority
+	for _, record := range records {
+		if record.Priority < priority {
+			priority = record.Priority
+		}
+	}
+
+	var endpoints []Endpoint
+	for _, record := range records {
+		if record.Priority != priority {
+			continue
+		}
+
+		host := strings.TrimSuffix(record.Target, ".")
+		endpoints = append(endpoints, Endpoint{
+			URL:    d.scheme + "://" + net.JoinHostPort(host, strconv.Itoa(int(record.Port))),
+			Weight: int(record.Weight),
+		})
+	}
+
+	return endpoints
+}
+
+// Watch
+//
+//	looks the endpoints up and hands them to the *LoadBalancer, then refreshes them every TTL
+//	until ctx is done or stop is called, stop returns once refreshing has ended and may be called more than once
+//	returns error if the first lookup fails
+func (d *SRVDiscovery) Watch(ctx context.Context, balancer *LoadBalancer) (stop func(), err error) {
+	endpoints, err := d.Endpoints(ctx)
+	if err != nil {
+		return nil, err
+	}
+	balancer.SetEndpoints(endpoints...)
+
+	ctx, cancel := context.WithCancel(ctx)
+	stopped := make(chan struct{})
+
+	go func() {
+		defer close(stopped)
+
+		ticker := time.NewTicker(d.ttl)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+
+			if endpoints, err := d.Endpoints(ctx); err == nil {
+				balancer.SetEndpoints(endpoints...)
+			}
+		}
+	}()
+
+	return func() {
+		cancel()
+		<-stopped
+	}, nil
+}
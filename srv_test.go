@@ -0,0 +1,172 @@
+package gopunch
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeSRV answers SRV lookups from a settable record set
+type fakeSRV struct {
+	mu      sync.Mutex
+	records []*net.SRV
+	err     error
+	lookups int
+}
+
+func (f *fakeSRV) set(records []*net.SRV, err error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.records, f.err = records, err
+}
+
+func (f *fakeSRV) LookupSRV(ctx context.Context, service, proto, name string) (string, []*net.SRV, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.lookups++
+	if service != "api" || proto != "tcp" || name != "example.com" {
+		return "", nil, errors.New("unexpected name " + service + proto + name)
+	}
+
+	return "_api._tcp.example.com.", f.records, f.err
+}
+
+func Test_NewSRVDiscovery(t *testing.T) {
+	for _, name := range []string{"example.com", "_api.example.com", "api._tcp.example.com", "_._tcp.example.com", "_api._tcp."} {
+		t.Log("Given malformed name " + name + "; NewSRVDiscovery should fail")
+		if _, err := NewSRVDiscovery(name, "https", time.Minute); err != ErrInvalidSRVName {
+			t.Fatal(err)
+		}
+	}
+
+	for _, ttl := range []time.Duration{0, -time.Second} {
+		t.Log("Given ttl " + ttl.String() + "; NewSRVDiscovery should fail")
+		if _, err := NewSRVDiscovery("_api._tcp.example.com", "https", ttl); err != ErrInvalidSRVTTL {
+			t.Fatal(err)
+		}
+	}
+}
+
+func Test_SRVDiscovery(t *testing.T) {
+	clock := withFrozenClock(t, time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+
+	fake := &fakeSRV{}
+	fake.set([]*net.SRV{
+		{Target: "a.example.com.", Port: 8443, Priority: 10, Weight: 60},
+		{Target: "b.example.com.", Port: 8443, Priority: 10, Weight: 40},
+		{Target: "backup.example.com.", Port: 443, Priority: 20, Weight: 100},
+	}, nil)
+
+	discovery, err := NewSRVDiscovery("_api._tcp.example.com.", "https", time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	discovery.SetLookup(fake.LookupSRV)
+
+	t.Log("Given records of two priorities; only the lowest priority should become weighted endpoints")
+	endpoints, err := discovery.Endpoints(context.Background())
+	expected := []Endpoint{{URL: "https://a.example.com:8443", Weight: 60}, {URL: "https://b.example.com:8443", Weight: 40}}
+	if err != nil || !reflect.DeepEqual(endpoints, expected) {
+		t.Fatal(endpoints, err)
+	}
+
+	t.Log("Given answer within the TTL; it should be served from cache")
+	fake.set([]*net.SRV{{Target: "c.example.com.", Port: 80, Weight: 1}}, nil)
+	discovery.Endpoints(context.Background())
+	if fake.lookups != 1 {
+		t.Fatal(fake.lookups)
+	}
+
+	t.Log("Given expired answer; it should be looked up again")
+	*clock = clock.Add(time.Minute)
+	endpoints, _ = discovery.Endpoints(context.Background())
+	if fake.lookups != 2 || !reflect.DeepEqual(endpoints, []Endpoint{{URL: "https://c.example.com:80", Weight: 1}}) {
+		t.Fatal(fake.lookups, endpoints)
+	}
+
+	t.Log("Given failed refresh; previous endpoints should be kept along with the error")
+	*clock = clock.Add(time.Minute)
+	fake.set(nil, errors.New("servfail"))
+	endpoints, err = discovery.Endpoints(context.Background())
+	if err == nil || len(endpoints) != 1 {
+		t.Fatal(endpoints, err)
+	}
+
+	t.Log("Given empty answer; it should be an error")
+	fake.set(nil, nil)
+	if _, err = discovery.Endpoints(context.Background()); err == nil {
+		t.Fail()
+	}
+}
+
+func Test_SRVDiscoveryWatch(t *testing.T) {
+	replicaA := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, "a")
+	}))
+	defer replicaA.Close()
+
+	replicaB := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, "b")
+	}))
+	defer replicaB.Close()
+
+	srvRecord := func(server *httptest.Server) *net.SRV {
+		u, _ := url.Parse(server.URL)
+		port, _ := strconv.Atoi(u.Port())
+		return &net.SRV{Target: u.Hostname() + ".", Port: uint16(port), Weight: 1}
+	}
+
+	fake := &fakeSRV{}
+	discovery, _ := NewSRVDiscovery("_api._tcp.example.com", "http", 10*time.Millisecond)
+	discovery.SetLookup(fake.LookupSRV)
+	balancer := NewLoadBalancer(BalanceWeighted)
+
+	t.Log("Given failing first lookup; Watch should fail")
+	fake.set(nil, errors.New("nxdomain"))
+	if _, err := discovery.Watch(context.Background(), balancer); err == nil {
+		t.Fatal("expected error")
+	}
+
+	t.Log("Given discovered endpoint; client should reach it")
+	fake.set([]*net.SRV{srvRecord(replicaA)}, nil)
+	stop, err := discovery.Watch(context.Background(), balancer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer stop()
+
+	client := New("")
+	client.SetLoadBalancer(balancer)
+	if body := readBody(t, client.Get(context.Background(), "/")); body != "a" {
+		t.Fatal(body)
+	}
+
+	t.Log("Given changed records; endpoints should be refreshed after the TTL")
+	fake.set([]*net.SRV{srvRecord(replicaB)}, nil)
+	deadline := time.Now().Add(2 * time.Second)
+	for readBody(t, client.Get(context.Background(), "/")) != "b" {
+		if time.Now().After(deadline) {
+			t.Fatal(balancer.Endpoints())
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	t.Log("Given stopped watch; endpoints should no longer be refreshed")
+	stop()
+	fake.set([]*net.SRV{srvRecord(replicaA)}, nil)
+	time.Sleep(50 * time.Millisecond)
+	if body := readBody(t, client.Get(context.Background(), "/")); body != "b" {
+		t.Fatal(body)
+	}
+}
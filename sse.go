@@ -0,0 +1,260 @@
+package gopunch
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"io"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+var ErrNotEventStream = errors.New("response is not a text/event-stream")
+
+// DefaultRetryDelay
+//
+//	wait before reconnecting until the server sends a retry field
+const DefaultRetryDelay = 3 * time.Second
+
+// Event
+//
+//	single server-sent event
+type Event struct {
+	ID    string
+	Event string
+	Data  string
+	// Retry is the reconnection delay sent along with the event, zero if none
+	Retry time.Duration
+}
+
+// EventStream
+//
+//	reads server-sent events, reconnecting with Last-Event-ID when the connection drops
+//	the client should not have a timeout, it would cut long lived streams
+//	Next must be called from a single goroutine, Close may be called from any
+type EventStream struct {
+	client      *Client
+	endPoint    string
+	opts        []Option
+	ctx         context.Context
+	cancel      context.CancelFunc
+	reader      *bufio.Reader
+	lastEventID string
+	retry       time.Duration
+
+	// mu guards body, which Close shuts while Next may be reading from it
+	mu   sync.Mutex
+	body io.ReadCloser
+}
+
+// Stream
+//
+//	takes context, endpoint and option functions
+//	returns *EventStream, the connection is opened on the first call to Next
+func (c *Client) Stream(ctx context.Context, endPoint string, opts ...Option) *EventStream {
+	streamCtx, cancel := context.WithCancel(ctx)
+
+	return &EventStream{
+		client:   c,
+		endPoint: endPoint,
+		opts:     opts,
+		ctx:      streamCtx,
+		cancel:   cancel,
+		retry:    DefaultRetryDelay,
+	}
+}
+
+// Next
+//
+//	blocks until the next event arrives
+//	returns the context error once the stream is closed or its context is done
+func (s *EventStream) Next() (Event, error) {
+	for {
+		if s.reader == nil {
+			err := s.connect()
+			if errors.Is(err, ErrNotEventStream) || isStatusError(err) || s.ctx.Err() != nil {
+				return Event{}, err
+			}
+
+			if err != nil {
+				if err := s.wait(); err != nil {
+					return Event{}, err
+				}
+				continue
+			}
+		}
+
+		event, err := s.readEvent()
+		if err == nil {
+			return event, nil
+		}
+
+		s.disconnect()
+		if s.ctx.Err() != nil {
+			return Event{}, s.ctx.Err()
+		}
+
+		if err := s.wait(); err != nil {
+			return Event{}, err
+		}
+	}
+}
+
+// LastEventID
+//
+//	returns the id of the last received event
+func (s *EventStream) LastEventID() string {
+	return s.lastEventID
+}
+
+// Close
+//
+//	stops the stream and closes the connection, a blocked Next returns the context error
+func (s *EventStream) Close() error {
+	s.cancel()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.body != nil {
+		s.body.Close()
+		s.body = nil
+	}
+
+	return nil
+}
+
+func isStatusError(err error) bool {
+	var statusErr *StatusError
+	return errors.As(err, &statusErr)
+}
+
+func (s *EventStream) connect() error {
+	headers := map[string]string{
+		"Accept":        "text/event-stream",
+		"Cache-Control": "no-cache",
+	}
+
+	if s.lastEventID != "" {
+		headers["Last-Event-ID"] = s.lastEventID
+	}
+
+	opts := append(append([]Option{}, s.opts...), WithHeaders(headers))
+	resp := s.client.Get(s.ctx, s.endPoint, opts...)
+	if resp.Err() != nil {
+		return resp.Err()
+	}
+
+	httpResponse := resp.HttpResponse()
+	if httpResponse.StatusCode != http.StatusOK {
+		resp.Close()
+		return &StatusError{StatusCode: httpResponse.StatusCode, Status: httpResponse.Status}
+	}
+
+	mediaType, _, _ := mime.ParseMediaType(httpResponse.Header.Get("Content-Type"))
+	if mediaType != "text/event-stream" {
+		resp.Close()
+		return ErrNotEventStream
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// Close may have run while connecting, it would not have seen this body
+	if err := s.ctx.Err(); err != nil {
+		httpResponse.Body.Close()
+		return err
+	}
+
+	s.body = httpResponse.Body
+	s.reader = bufio.NewReader(httpResponse.Body)
+
+	return nil
+}
+
+func (s *EventStream) disconnect() {
+	s.mu.Lock()
+	body := s.body
+	s.body = nil
+	s.mu.Unlock()
+
+	if body != nil {
+		body.Close()
+	}
+
+	s.reader = nil
+}
+
+func (s *EventStream) wait() error {
+	timer := time.NewTimer(s.retry)
+	defer timer.Stop()
+
+	select {
+	case <-s.ctx.Done():
+		return s.ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// readEvent reads lines until a blank line dispatches an event
+// a connection dropping mid event discards the partial event, id included
+func (s *EventStream) readEvent() (Event, error) {
+	var event Event
+	var data strings.Builder
+	hasData := false
+	id := s.lastEventID
+
+	for {
+		line, err := s.reader.ReadString('\n')
+		if err != nil {
+			return Event{}, err
+		}
+
+		line = strings.TrimSuffix(strings.TrimSuffix(line, "\n"), "\r")
+		if line == "" {
+			s.lastEventID = id
+			if !hasData {
+				event = Event{}
+				continue
+			}
+
+			event.ID = id
+			event.Data = strings.TrimSuffix(data.String(), "\n")
+			if event.Event == "" {
+				event.Event = "message"
+			}
+
+			return event, nil
+		}
+
+		if strings.HasPrefix(line, ":") {
+			continue
+		}
+
+		field, value, _ := strings.Cut(line, ":")
+		value = strings.TrimPrefix(value, " ")
+
+		switch field {
+		case "event":
+			event.Event = value
+		case "data":
+			data.WriteString(value)
+			data.WriteString("\n")
+			hasData = true
+		case "id":
+			if !strings.ContainsRune(value, 0) {
+				id = value
+			}
+		case "retry":
+			if millis, err := strconv.Atoi(value); err == nil && millis >= 0 {
+				s.retry = time.Duration(millis) * time.Millisecond
+				event.Retry = s.retry
+			}
+		}
+	}
+}
@@ -0,0 +1,135 @@
+package gopunch
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+func Test_Stream(t *testing.T) {
+	t.Log("Given stream dropping after two events; client should reconnect with Last-Event-ID and keep reading")
+	var mu sync.Mutex
+	var lastEventIDs []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		lastEventIDs = append(lastEventIDs, r.Header.Get("Last-Event-ID"))
+		connection := len(lastEventIDs)
+		mu.Unlock()
+
+		w.Header().Set("Content-Type", "text/event-stream")
+		if connection == 1 {
+			fmt.Fprint(w, ": comment\nretry: 10\n\nid: 1\nevent: greeting\ndata: hello\ndata: world\n\n")
+			fmt.Fprint(w, "id: 2\r\ndata: second\r\n\r\n")
+			fmt.Fprint(w, "id: 99\ndata: partial")
+			return
+		}
+
+		fmt.Fprint(w, "id: 3\ndata: third\n\n")
+		w.(http.Flusher).Flush()
+		<-r.Context().Done()
+	}))
+	defer server.Close()
+
+	stream := New(server.URL).Stream(context.Background(), "/events")
+	defer stream.Close()
+
+	expected := []Event{
+		{ID: "1", Event: "greeting", Data: "hello\nworld"},
+		{ID: "2", Event: "message", Data: "second"},
+		{ID: "3", Event: "message", Data: "third"},
+	}
+
+	for _, want := range expected {
+		event, err := stream.Next()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if event.ID != want.ID || event.Event != want.Event || event.Data != want.Data {
+			t.Logf("expected %+v, got %+v", want, event)
+			t.Fail()
+		}
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(lastEventIDs) != 2 || lastEventIDs[1] != "2" {
+		t.Logf("unexpected Last-Event-ID values %v", lastEventIDs)
+		t.Fail()
+	}
+}
+
+func Test_Stream_Cancel(t *testing.T) {
+	t.Log("Given stream waiting for events; cancelling the context should stop Next")
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.(http.Flusher).Flush()
+		<-r.Context().Done()
+	}))
+	defer server.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	stream := New(server.URL).Stream(ctx, "/events")
+	defer stream.Close()
+
+	go func() {
+		time.Sleep(20 * time.Millisecond)
+		cancel()
+	}()
+
+	if _, err := stream.Next(); !errors.Is(err, context.Canceled) {
+		t.Log(err)
+		t.Fail()
+	}
+}
+
+func Test_Stream_Close(t *testing.T) {
+	t.Log("Given Next blocked on an open stream; Close from another goroutine should stop it")
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		fmt.Fprint(w, "data: first\n\n")
+		w.(http.Flusher).Flush()
+		<-r.Context().Done()
+	}))
+	defer server.Close()
+
+	stream := New(server.URL).Stream(context.Background(), "/events")
+	if event, err := stream.Next(); err != nil || event.Data != "first" {
+		t.Fatal(event, err)
+	}
+
+	go func() {
+		time.Sleep(20 * time.Millisecond)
+		stream.Close()
+	}()
+
+	if _, err := stream.Next(); !errors.Is(err, context.Canceled) {
+		t.Fatal(err)
+	}
+
+	t.Log("Given closed stream; Next should fail at once")
+	if _, err := stream.Next(); !errors.Is(err, context.Canceled) {
+		t.Fatal(err)
+	}
+}
+
+func Test_Stream_NotEventStream(t *testing.T) {
+	t.Log("Given endpoint answering with JSON; Next should fail without reconnecting")
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{}`))
+	}))
+	defer server.Close()
+
+	stream := New(server.URL).Stream(context.Background(), "/events")
+	defer stream.Close()
+
+	if _, err := stream.Next(); !errors.Is(err, ErrNotEventStream) {
+		t.Fail()
+	}
+}
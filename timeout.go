@@ -0,0 +1,319 @@
+package gopunch
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptrace"
+	"sync"
+	"time"
+)
+
+// TimeoutPhase
+//
+//	part of a request a TimeoutError happened in
+type TimeoutPhase string
+
+const (
+	// PhaseRequest is the whole request, as limited by WithTimeout and WithDeadline
+	PhaseRequest TimeoutPhase = "request"
+	// PhaseConnect is opening the connection
+	PhaseConnect TimeoutPhase = "connect"
+	// PhaseTLSHandshake is the TLS handshake
+	PhaseTLSHandshake TimeoutPhase = "tls handshake"
+	// PhaseResponseHeader is waiting for the response once the request is written
+	PhaseResponseHeader TimeoutPhase = "response header"
+	// PhaseBodyIdle is waiting for the next bytes of the response body
+	PhaseBodyIdle TimeoutPhase = "body idle"
+)
+
+// TimeoutError
+//
+//	returned when a request ran out of time, Phase tells which timeout expired
+type TimeoutError struct {
+	Phase    TimeoutPhase
+	Duration time.Duration
+	Err      error
+}
+
+func (e *TimeoutError) Error() string {
+	return fmt.Sprintf("%s timeout after %s: %v", e.Phase, e.Duration, e.Err)
+}
+
+func (e *TimeoutError) Unwrap() error {
+	return e.Err
+}
+
+// Timeout
+//
+//	always true, like the timeouts of net.Error
+func (e *TimeoutError) Timeout() bool {
+	return true
+}
+
+// requestTimeouts holds the timeouts set by options, phase timeouts apply to each attempt
+type requestTimeouts struct {
+	deadline       time.Time
+	duration       time.Duration
+	connect        time.Duration
+	tlsHandshake   time.Duration
+	responseHeader time.Duration
+	bodyIdle       time.Duration
+}
+
+type requestTimeoutsKey struct{}
+
+func requestTimeoutsOf(ctx context.Context) *requestTimeouts {
+	timeouts, _ := ctx.Value(requestTimeoutsKey{}).(*requestTimeouts)
+	return timeouts
+}
+
+// setTimeouts returns the timeouts of req, attaching new ones to its context on first use
+func setTimeouts(req *http.Request) *requestTimeouts {
+	if timeouts := requestTimeoutsOf(req.Context()); timeouts != nil {
+		return timeouts
+	}
+
+	timeouts := &requestTimeouts{}
+	*req = *req.WithContext(context.WithValue(req.Context(), requestTimeoutsKey{}, timeouts))
+
+	return timeouts
+}
+
+func (t *requestTimeouts) setDeadline(deadline time.Time) {
+	if t.deadline.IsZero() || deadline.Before(t.deadline) {
+		t.deadline = deadline
+		t.duration = time.Until(deadline)
+	}
+}
+
+func (t *requestTimeouts) phased() bool {
+	return t.connect > 0 || t.tlsHandshake > 0 || t.responseHeader > 0 || t.bodyIdle > 0
+}
+
+// WithTimeout
+//
+//	limits the whole request, retries and reading the body included, to timeout
+//	an expired timeout is a *TimeoutError with PhaseRequest
+func WithTimeout(timeout time.Duration) Option {
+	return func(req *http.Request) {
+		setTimeouts(req).setDeadline(time.Now().Add(timeout))
+	}
+}
+
+// WithDeadline
+//
+//	like WithTimeout, ending the request at deadline
+func WithDeadline(deadline time.Time) Option {
+	return func(req *http.Request) {
+		setTimeouts(req).setDeadline(deadline)
+	}
+}
+
+// WithConnectTimeout
+//
+//	limits resolving and dialing each connection, reused connections are not affected
+//	an expired timeout is a *TimeoutError with PhaseConnect
+func WithConnectTimeout(timeout time.Duration) Option {
+	return func(req *http.Request) {
+		setTimeouts(req).connect = timeout
+	}
+}
+
+// WithTLSHandshakeTimeout
+//
+//	limits the TLS handshake of each connection
+//	an expired timeout is a *TimeoutError with PhaseTLSHandshake
+func WithTLSHandshakeTimeout(timeout time.Duration) Option {
+	return func(req *http.Request) {
+		setTimeouts(req).tlsHandshake = timeout
+	}
+}
+
+// WithResponseHeaderTimeout
+//
+//	limits the wait for the response headers once the request is written
+//	an expired timeout is a *TimeoutError with PhaseResponseHeader
+func WithResponseHeaderTimeout(timeout time.Duration) Option {
+	return func(req *http.Request) {
+		setTimeouts(req).responseHeader = timeout
+	}
+}
+
+// WithIdleReadTimeout
+//
+//	limits the time the response body may go without new bytes, counted from the headers and then from each read
+//	an expired timeout is a *TimeoutError with PhaseBodyIdle
+func WithIdleReadTimeout(timeout time.Duration) Option {
+	return func(req *http.Request) {
+		setTimeouts(req).bodyIdle = timeout
+	}
+}
+
+// sendBefore sends req with a child context ending at the deadline of the timeouts
+func (c *Client) sendBefore(req *http.Request, timeouts *requestTimeouts) (*http.Response, error) {
+	ctx, cancel := context.WithDeadline(req.Context(), timeouts.deadline)
+	expired := func(err error) error {
+		if ctx.Err() == context.DeadlineExceeded && req.Context().Err() == nil {
+			return &TimeoutError{Phase: PhaseRequest, Duration: timeouts.duration, Err: err}
+		}
+		return err
+	}
+
+	resp, err := c.retry(req.WithContext(ctx))
+	if err != nil {
+		cancel()
+		return nil, expired(err)
+	}
+
+	resp.Body = keepWritable(resp.Body, &timeoutBody{ReadCloser: resp.Body, wrap: expired, close: cancel})
+
+	return resp, nil
+}
+
+// exchange sends a single request, enforcing the phase timeouts it carries
+func (c *Client) exchange(req *http.Request) (*http.Response, error) {
+	timeouts := requestTimeoutsOf(req.Context())
+	if timeouts == nil || !timeouts.phased() {
+		return c.httpClient.Do(req)
+	}
+
+	ctx, cancel := context.WithCancel(req.Context())
+	watch := &phaseWatch{cancel: cancel, timers: map[TimeoutPhase]*time.Timer{}}
+	ctx = httptrace.WithClientTrace(ctx, &httptrace.ClientTrace{
+		// connecting spans name resolution and dialing, whatever dial function the transport uses
+		GetConn: func(hostPort string) { watch.start(PhaseConnect, timeouts.connect) },
+		TLSHandshakeStart: func() {
+			watch.stop(PhaseConnect)
+			watch.start(PhaseTLSHandshake, timeouts.tlsHandshake)
+		},
+		TLSHandshakeDone: func(tls.ConnectionState, error) { watch.stop(PhaseTLSHandshake) },
+		GotConn: func(httptrace.GotConnInfo) {
+			watch.stop(PhaseConnect)
+			watch.stop(PhaseTLSHandshake)
+		},
+		WroteRequest:         func(httptrace.WroteRequestInfo) { watch.start(PhaseResponseHeader, timeouts.responseHeader) },
+		GotFirstResponseByte: func() { watch.stop(PhaseResponseHeader) },
+	})
+
+	resp, err := c.httpClient.Do(req.WithContext(ctx))
+	if err != nil {
+		watch.stopAll()
+		cancel()
+		return nil, watch.wrap(err)
+	}
+
+	watch.stopAll()
+	watch.start(PhaseBodyIdle, timeouts.bodyIdle)
+
+	read := func() { watch.restart(PhaseBodyIdle, timeouts.bodyIdle) }
+	closeBody := func() {
+		watch.stopAll()
+		cancel()
+	}
+	resp.Body = keepWritable(resp.Body, &timeoutBody{ReadCloser: resp.Body, wrap: watch.wrap, read: read, close: closeBody})
+
+	return resp, nil
+}
+
+// phaseWatch cancels a request once one of its phases outlives its timeout, remembering which one
+type phaseWatch struct {
+	mu      sync.Mutex
+	cancel  context.CancelFunc
+	timers  map[TimeoutPhase]*time.Timer
+	expired *TimeoutError
+}
+
+func (w *phaseWatch) start(phase TimeoutPhase, timeout time.Duration) {
+	if timeout <= 0 {
+		return
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.timers[phase] != nil {
+		return
+	}
+
+	w.timers[phase] = time.AfterFunc(timeout, func() {
+		w.mu.Lock()
+		if w.expired == nil {
+			w.expired = &TimeoutError{Phase: phase, Duration: timeout}
+		}
+		w.mu.Unlock()
+
+		w.cancel()
+	})
+}
+
+func (w *phaseWatch) stop(phase TimeoutPhase) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if timer := w.timers[phase]; timer != nil {
+		timer.Stop()
+		delete(w.timers, phase)
+	}
+}
+
+func (w *phaseWatch) restart(phase TimeoutPhase, timeout time.Duration) {
+	w.stop(phase)
+	w.start(phase, timeout)
+}
+
+func (w *phaseWatch) stopAll() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	for phase, timer := range w.timers {
+		timer.Stop()
+		delete(w.timers, phase)
+	}
+}
+
+// wrap turns err into the *TimeoutError of the expired phase, if any
+func (w *phaseWatch) wrap(err error) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.expired == nil {
+		return err
+	}
+
+	expired := *w.expired
+	expired.Err = err
+
+	return &expired
+}
+
+// timeoutBody reports read errors caused by an expired timeout as *TimeoutError
+type timeoutBody struct {
+	io.ReadCloser
+	wrap  func(err error) error
+	read  func()
+	close func()
+	once  sync.Once
+}
+
+func (b *timeoutBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	if err != nil && err != io.EOF {
+		return n, b.wrap(err)
+	}
+
+	if b.read != nil && err == nil {
+		b.read()
+	}
+
+	return n, err
+}
+
+func (b *timeoutBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.once.Do(b.close)
+
+	return err
+}
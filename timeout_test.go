@@ -0,0 +1,168 @@
+package gopunch
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// stall blocks a handler until the client gives up
+func stall(r *http.Request) {
+	select {
+	case <-r.Context().Done():
+	case <-time.After(5 * time.Second):
+	}
+}
+
+func timeoutPhase(err error) TimeoutPhase {
+	var timeoutErr *TimeoutError
+	if !errors.As(err, &timeoutErr) || !timeoutErr.Timeout() {
+		return ""
+	}
+
+	return timeoutErr.Phase
+}
+
+func Test_WithTimeout(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/body" {
+			io.WriteString(w, "partial")
+			w.(http.Flusher).Flush()
+		}
+		stall(r)
+	}))
+	defer server.Close()
+
+	client := New(server.URL)
+
+	t.Log("Given slow server and timeout; request should fail with a request timeout")
+	resp := client.Get(context.Background(), "/", WithTimeout(50*time.Millisecond))
+	if timeoutPhase(resp.Err()) != PhaseRequest {
+		t.Fatal(resp.Err())
+	}
+
+	t.Log("Given two deadlines; the earliest should apply")
+	start := time.Now()
+	resp = client.Get(context.Background(), "/", WithDeadline(time.Now().Add(time.Hour)), WithDeadline(time.Now().Add(50*time.Millisecond)))
+	if timeoutPhase(resp.Err()) != PhaseRequest || time.Since(start) > 2*time.Second {
+		t.Fatal(resp.Err())
+	}
+
+	t.Log("Given timeout expiring while reading the body; read should fail with a request timeout")
+	resp = client.Get(context.Background(), "/body", WithTimeout(100*time.Millisecond))
+	if resp.Err() != nil {
+		t.Fatal(resp.Err())
+	}
+	_, err := io.ReadAll(resp.HttpResponse().Body)
+	resp.Close()
+	if timeoutPhase(err) != PhaseRequest {
+		t.Fatal(err)
+	}
+
+	t.Log("Given caller context canceled first; error should not be a timeout")
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(20*time.Millisecond, cancel)
+	resp = client.Get(ctx, "/", WithTimeout(time.Hour))
+	if resp.Err() == nil || timeoutPhase(resp.Err()) != "" {
+		t.Fatal(resp.Err())
+	}
+}
+
+func Test_PhaseTimeouts(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/slow-header":
+			stall(r)
+		case "/slow-body":
+			io.WriteString(w, "partial")
+			w.(http.Flusher).Flush()
+			stall(r)
+		case "/trickle":
+			for i := 0; i < 5; i++ {
+				io.WriteString(w, "chunk")
+				w.(http.Flusher).Flush()
+				time.Sleep(20 * time.Millisecond)
+			}
+		}
+	}))
+	defer server.Close()
+
+	silent, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer silent.Close()
+	go func() {
+		for {
+			conn, err := silent.Accept()
+			if err != nil {
+				return
+			}
+			// connections stay open and silent until the listener is closed
+			defer conn.Close()
+		}
+	}()
+
+	blackhole := New("http://unreachable.invalid")
+	blackhole.SetDialContext(func(ctx context.Context, network, addr string) (net.Conn, error) {
+		<-ctx.Done()
+		return nil, ctx.Err()
+	})
+
+	testCases := []struct {
+		Title    string
+		Client   *Client
+		EndPoint string
+		Option   Option
+		Expected TimeoutPhase
+	}{
+		{Title: "Given dial that never completes; it should fail with a connect timeout", Client: blackhole, EndPoint: "/", Option: WithConnectTimeout(50 * time.Millisecond), Expected: PhaseConnect},
+		{Title: "Given server that never answers the handshake; it should fail with a tls handshake timeout", Client: New("https://" + silent.Addr().String()), EndPoint: "/", Option: WithTLSHandshakeTimeout(50 * time.Millisecond), Expected: PhaseTLSHandshake},
+		{Title: "Given server that never sends headers; it should fail with a response header timeout", Client: New(server.URL), EndPoint: "/slow-header", Option: WithResponseHeaderTimeout(50 * time.Millisecond), Expected: PhaseResponseHeader},
+	}
+
+	for _, testCase := range testCases {
+		t.Log(testCase.Title)
+		resp := testCase.Client.Get(context.Background(), testCase.EndPoint, testCase.Option)
+		if phase := timeoutPhase(resp.Err()); phase != testCase.Expected {
+			t.Fatal(phase, resp.Err())
+		}
+	}
+
+	client := New(server.URL)
+
+	t.Log("Given body that stops flowing; read should fail with a body idle timeout")
+	resp := client.Get(context.Background(), "/slow-body", WithIdleReadTimeout(50*time.Millisecond))
+	if resp.Err() != nil {
+		t.Fatal(resp.Err())
+	}
+	body, err := io.ReadAll(resp.HttpResponse().Body)
+	resp.Close()
+	if timeoutPhase(err) != PhaseBodyIdle || string(body) != "partial" {
+		t.Fatal(string(body), err)
+	}
+
+	t.Log("Given body trickling faster than the idle timeout; it should be read whole")
+	resp = client.Get(context.Background(), "/trickle", WithIdleReadTimeout(time.Second), WithConnectTimeout(time.Second), WithResponseHeaderTimeout(time.Second))
+	if resp.Err() != nil {
+		t.Fatal(resp.Err())
+	}
+	body, err = io.ReadAll(resp.HttpResponse().Body)
+	resp.Close()
+	if err != nil || string(body) != "chunkchunkchunkchunkchunk" {
+		t.Fatal(string(body), err)
+	}
+
+	t.Log("Given phase timeout and retries; each attempt should get its own timeout")
+	client.SetRetryPolicy(&RetryPolicy{MaxAttempts: 2, Backoff: time.Millisecond})
+	start := time.Now()
+	resp = client.Get(context.Background(), "/slow-header", WithResponseHeaderTimeout(50*time.Millisecond))
+	if timeoutPhase(resp.Err()) != PhaseResponseHeader || time.Since(start) < 100*time.Millisecond {
+		t.Fatal(resp.Err(), time.Since(start))
+	}
+}
@@ -0,0 +1,142 @@
+package gopunch
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+var ErrEmptyToken = errors.New("token source returned an empty token")
+
+// TokenExpiryDelta
+//
+//	tokens are refreshed this long before they expire
+const TokenExpiryDelta = 10 * time.Second
+
+// Token
+//
+//	access token with its type and expiry, a zero Expiry never expires
+type Token struct {
+	AccessToken string
+	TokenType   string
+	Expiry      time.Time
+}
+
+// Valid
+//
+//	reports whether the token is set and not about to expire
+func (t *Token) Valid() bool {
+	if t == nil || t.AccessToken == "" {
+		return false
+	}
+
+	return t.Expiry.IsZero() || nowFunc().Add(TokenExpiryDelta).Before(t.Expiry)
+}
+
+// TokenSource
+//
+//	fetches new tokens
+type TokenSource interface {
+	Token(ctx context.Context) (*Token, error)
+}
+
+// TokenSourceFunc
+//
+//	lets a plain function be used as TokenSource
+type TokenSourceFunc func(ctx context.Context) (*Token, error)
+
+func (f TokenSourceFunc) Token(ctx context.Context) (*Token, error) {
+	return f(ctx)
+}
+
+// TokenAuth
+//
+//	Authenticator that caches the token of a TokenSource and fetches a new one
+//	lazily when it is about to expire or the server answered 401
+//	safe for concurrent use, concurrent requests share a single fetch
+type TokenAuth struct {
+	mu     sync.Mutex
+	source TokenSource
+	token  *Token
+}
+
+// NewTokenAuth
+//
+//	takes a TokenSource
+//	returns *TokenAuth
+func NewTokenAuth(source TokenSource) *TokenAuth {
+	return &TokenAuth{source: source}
+}
+
+// Token
+//
+//	returns the cached token, fetching a new one if it is missing or about to expire
+func (a *TokenAuth) Token(ctx context.Context) (*Token, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if a.token.Valid() {
+		return a.token, nil
+	}
+
+	return a.fetch(ctx)
+}
+
+func (a *TokenAuth) fetch(ctx context.Context) (*Token, error) {
+	token, err := a.source.Token(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	if token == nil || token.AccessToken == "" {
+		return nil, ErrEmptyToken
+	}
+
+	a.token = token
+
+	return token, nil
+}
+
+// Authenticate
+//
+//	sets "Authorization: <type> <token>", Bearer when the token has no type
+func (a *TokenAuth) Authenticate(req *http.Request) error {
+	token, err := a.Token(req.Context())
+	if err != nil {
+		return err
+	}
+
+	req.Header.Set("Authorization", authorizationValue(token))
+
+	return nil
+}
+
+// Refresh
+//
+//	drops the token the server rejected and fetches a new one
+//	if another request already replaced that token, the newer one is kept
+func (a *TokenAuth) Refresh(ctx context.Context, resp *http.Response) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if a.token != nil && resp.Request != nil &&
+		resp.Request.Header.Get("Authorization") != authorizationValue(a.token) && a.token.Valid() {
+		return nil
+	}
+
+	_, err := a.fetch(ctx)
+
+	return err
+}
+
+func authorizationValue(token *Token) string {
+	tokenType := token.TokenType
+	if tokenType == "" || strings.EqualFold(tokenType, "bearer") {
+		tokenType = "Bearer"
+	}
+
+	return tokenType + " " + token.AccessToken
+}
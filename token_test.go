@@ -0,0 +1,69 @@
+package gopunch
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func Test_TokenAuth_Expiry(t *testing.T) {
+	t.Log("Given token close to expiry; TokenAuth should reuse it while valid and fetch a new one before it expires")
+	now := withFrozenClock(t, time.Now())
+	var fetches int32
+	auth := NewTokenAuth(TokenSourceFunc(func(ctx context.Context) (*Token, error) {
+		atomic.AddInt32(&fetches, 1)
+		return &Token{AccessToken: "t", TokenType: "MAC", Expiry: nowFunc().Add(time.Minute)}, nil
+	}))
+
+	req, _ := http.NewRequest(http.MethodGet, "http://example.com", nil)
+	for i := 0; i < 3; i++ {
+		if err := auth.Authenticate(req); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if fetches != 1 || req.Header.Get("Authorization") != "MAC t" {
+		t.Fail()
+	}
+
+	*now = now.Add(time.Minute - TokenExpiryDelta/2)
+	auth.Authenticate(req)
+
+	if fetches != 2 {
+		t.Fail()
+	}
+}
+
+func Test_TokenAuth_Concurrent(t *testing.T) {
+	t.Log("Given many concurrent requests; TokenAuth should fetch a token only once")
+	var fetches int32
+	auth := NewTokenAuth(TokenSourceFunc(func(ctx context.Context) (*Token, error) {
+		atomic.AddInt32(&fetches, 1)
+		time.Sleep(10 * time.Millisecond)
+		return &Token{AccessToken: "t"}, nil
+	}))
+
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			auth.Token(context.Background())
+		}()
+	}
+	wg.Wait()
+
+	if fetches != 1 {
+		t.Fail()
+	}
+
+	t.Log("Given 401 for a token that was already replaced; Refresh should keep the newer token")
+	stale, _ := http.NewRequest(http.MethodGet, "http://example.com", nil)
+	stale.Header.Set("Authorization", "Bearer old")
+	if err := auth.Refresh(context.Background(), &http.Response{Request: stale}); err != nil || fetches != 1 {
+		t.Fail()
+	}
+}
@@ -0,0 +1,38 @@
+package gopunch
+
+import (
+	"crypto/tls"
+	"errors"
+	"net/http"
+)
+
+var ErrCustomTransport = errors.New("http client has a custom RoundTripper, transport options need an *http.Transport")
+
+// transport returns the *http.Transport of the client,
+// cloning http.DefaultTransport the first time so other clients are not affected
+func (c *Client) transport() (*http.Transport, error) {
+	switch transport := c.httpClient.Transport.(type) {
+	case nil:
+		cloned := http.DefaultTransport.(*http.Transport).Clone()
+		c.httpClient.Transport = cloned
+		return cloned, nil
+	case *http.Transport:
+		return transport, nil
+	}
+
+	return nil, ErrCustomTransport
+}
+
+// tlsConfig returns the TLS config of the client's transport, creating it if needed
+func (c *Client) tlsConfig() (*tls.Config, error) {
+	transport, err := c.transport()
+	if err != nil {
+		return nil, err
+	}
+
+	if transport.TLSClientConfig == nil {
+		transport.TLSClientConfig = &tls.Config{}
+	}
+
+	return transport.TLSClientConfig, nil
+}
@@ -0,0 +1,25 @@
+package gopunch
+
+import (
+	"io"
+	"net/http"
+	"testing"
+)
+
+func Test_Transport_Custom(t *testing.T) {
+	t.Log("Given custom RoundTripper; transport options should report ErrCustomTransport")
+	client := New("https://example.com")
+	client.HttpClient().Transport = roundTripperFunc(func(r *http.Request) (*http.Response, error) {
+		return nil, io.EOF
+	})
+
+	if _, err := client.tlsConfig(); err != ErrCustomTransport {
+		t.Fatal(err)
+	}
+}
+
+type roundTripperFunc func(r *http.Request) (*http.Response, error)
+
+func (f roundTripperFunc) RoundTrip(r *http.Request) (*http.Response, error) {
+	return f(r)
+}
@@ -0,0 +1,194 @@
+package gopunch
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+)
+
+var ErrMissingETag = errors.New("response has no ETag header")
+
+// ConflictError
+//
+//	returned by UpdateJSON when every attempt failed with 412 Precondition Failed
+type ConflictError struct {
+	Endpoint string
+	Attempts int
+}
+
+func (e *ConflictError) Error() string {
+	return fmt.Sprintf("conflicting update on %s after %d attempts", e.Endpoint, e.Attempts)
+}
+
+type updateConfig struct {
+	method         string
+	attempts       int
+	requestOptions []Option
+}
+
+// UpdateOption
+//
+//	can be used to customize UpdateJSON
+type UpdateOption func(cfg *updateConfig)
+
+// WithUpdateMethod
+//
+//	takes the method used to send the modified resource, PUT by default
+func WithUpdateMethod(method string) UpdateOption {
+	return func(cfg *updateConfig) {
+		cfg.method = method
+	}
+}
+
+// WithUpdateAttempts
+//
+//	takes how many times the fetch, mutate and send cycle is tried, 3 by default
+func WithUpdateAttempts(attempts int) UpdateOption {
+	return func(cfg *updateConfig) {
+		cfg.attempts = attempts
+	}
+}
+
+// WithUpdateRequestOptions
+//
+//	takes option functions applied to both the fetch and the update request
+func WithUpdateRequestOptions(opts ...Option) UpdateOption {
+	return func(cfg *updateConfig) {
+		cfg.requestOptions = append(cfg.requestOptions, opts...)
+	}
+}
+
+// UpdateJSON
+//
+//	fetches endpoint into dest and captures its ETag, applies mutate
+//	and sends dest back with If-Match
+//	on 412 Precondition Failed the whole cycle is retried
+//	returns *ConflictError when attempts run out
+func UpdateJSON[T any](ctx context.Context, c *Client, endPoint string, dest *T, mutate func(*T) error, opts ...UpdateOption) error {
+	cfg := &updateConfig{
+		method:   http.MethodPut,
+		attempts: 3,
+	}
+
+	for _, opt := range opts {
+		opt(cfg)
+	}
+
+	for attempt := 0; attempt < cfg.attempts; attempt++ {
+		var zero T
+		*dest = zero
+
+		etag, err := fetchForUpdate(ctx, c, endPoint, dest, cfg.requestOptions)
+		if err != nil {
+			return err
+		}
+
+		original, err := json.Marshal(dest)
+		if err != nil {
+			return err
+		}
+
+		if err := mutate(dest); err != nil {
+			return err
+		}
+
+		payload, err := updatePayload(cfg.method, original, dest)
+		if err != nil {
+			return err
+		}
+
+		reqOpts := append([]Option{WithHeaders(map[string]string{
+			"Content-Type": updateContentType(cfg.method),
+			"If-Match":     etag,
+		})}, cfg.requestOptions...)
+
+		resp := c.Custom(ctx, cfg.method, endPoint, payload, reqOpts...)
+		if resp.Err() != nil {
+			return resp.Err()
+		}
+
+		conflict, err := decodeUpdateResponse(resp, dest)
+		if err != nil {
+			return err
+		}
+
+		if !conflict {
+			return nil
+		}
+	}
+
+	return &ConflictError{
+		Endpoint: endPoint,
+		Attempts: cfg.attempts,
+	}
+}
+
+// updatePayload sends only the changes as a merge patch when patching
+func updatePayload(method string, original []byte, dest interface{}) ([]byte, error) {
+	if method == http.MethodPatch {
+		return CreateMergePatch(original, dest)
+	}
+
+	return json.Marshal(dest)
+}
+
+func updateContentType(method string) string {
+	if method == http.MethodPatch {
+		return ContentTypeMergePatch
+	}
+
+	return "application/json"
+}
+
+func fetchForUpdate(ctx context.Context, c *Client, endPoint string, dest interface{}, opts []Option) (string, error) {
+	// a cached representation could carry an outdated ETag
+	reqOpts := append([]Option{WithHeaders(map[string]string{
+		"Cache-Control": "no-cache",
+	})}, opts...)
+
+	resp := c.Get(ctx, endPoint, reqOpts...)
+	defer resp.Close()
+
+	if resp.Err() != nil {
+		return "", resp.Err()
+	}
+
+	httpResponse := resp.HttpResponse()
+	if httpResponse.StatusCode < 200 || httpResponse.StatusCode > 299 {
+		return "", &StatusError{StatusCode: httpResponse.StatusCode, Status: httpResponse.Status}
+	}
+
+	etag := httpResponse.Header.Get("ETag")
+	if etag == "" {
+		return "", ErrMissingETag
+	}
+
+	return etag, resp.JSONUnmarshal(dest)
+}
+
+func decodeUpdateResponse(resp *Response, dest interface{}) (bool, error) {
+	defer resp.Close()
+
+	httpResponse := resp.HttpResponse()
+	if httpResponse.StatusCode == http.StatusPreconditionFailed {
+		return true, nil
+	}
+
+	if httpResponse.StatusCode < 200 || httpResponse.StatusCode > 299 {
+		return false, &StatusError{StatusCode: httpResponse.StatusCode, Status: httpResponse.Status}
+	}
+
+	body, err := io.ReadAll(httpResponse.Body)
+	if err != nil {
+		return false, err
+	}
+
+	if len(body) == 0 {
+		return false, nil
+	}
+
+	return false, json.Unmarshal(body, dest)
+}
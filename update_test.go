@@ -0,0 +1,133 @@
+package gopunch
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+)
+
+type versionedTodo struct {
+	Title string `json:"title"`
+	Count int    `json:"count"`
+}
+
+// versionedServer serves a single todo and bumps its version behind the client's back
+// for the first `interfere` updates
+func versionedServer(interfere int) *httptest.Server {
+	var mu sync.Mutex
+	version := 1
+	todo := versionedTodo{Title: "todo"}
+
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+
+		etag := fmt.Sprintf(`"%d"`, version)
+		switch r.Method {
+		case http.MethodGet:
+			w.Header().Set("ETag", etag)
+			json.NewEncoder(w).Encode(todo)
+		case http.MethodPut, http.MethodPatch:
+			if interfere > 0 {
+				interfere--
+				version++
+				todo.Count += 100
+			}
+
+			if r.Header.Get("If-Match") != fmt.Sprintf(`"%d"`, version) {
+				w.WriteHeader(http.StatusPreconditionFailed)
+				return
+			}
+
+			json.NewDecoder(r.Body).Decode(&todo)
+			version++
+			w.Header().Set("ETag", fmt.Sprintf(`"%d"`, version))
+			json.NewEncoder(w).Encode(todo)
+		}
+	}))
+}
+
+var UpdateJSONTestCases = []struct {
+	Title         string
+	Interfere     int
+	Attempts      int
+	Method        string
+	ExpectedCount int
+	ExpectedErr   bool
+}{
+	{
+		Title:         "Given no concurrent writer; update should succeed on first attempt",
+		Interfere:     0,
+		Attempts:      3,
+		Method:        http.MethodPut,
+		ExpectedCount: 1,
+	},
+	{
+		Title:         "Given one concurrent write; update should retry after 412 and apply on fresh data",
+		Interfere:     1,
+		Attempts:      3,
+		Method:        http.MethodPatch,
+		ExpectedCount: 101,
+	},
+	{
+		Title:       "Given concurrent writes on every attempt; update should return ConflictError",
+		Interfere:   5,
+		Attempts:    2,
+		Method:      http.MethodPut,
+		ExpectedErr: true,
+	},
+}
+
+func Test_UpdateJSON(t *testing.T) {
+	for _, testCase := range UpdateJSONTestCases {
+		t.Log(testCase.Title)
+		server := versionedServer(testCase.Interfere)
+		client := New(server.URL)
+
+		var todo versionedTodo
+		err := UpdateJSON(context.Background(), client, "/todos/1", &todo, func(todo *versionedTodo) error {
+			todo.Count++
+			return nil
+		}, WithUpdateAttempts(testCase.Attempts), WithUpdateMethod(testCase.Method))
+
+		server.Close()
+
+		if testCase.ExpectedErr {
+			var conflict *ConflictError
+			if !errors.As(err, &conflict) || conflict.Attempts != testCase.Attempts {
+				t.Fail()
+			}
+			continue
+		}
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if todo.Count != testCase.ExpectedCount {
+			t.Logf("expected count %d, got %d", testCase.ExpectedCount, todo.Count)
+			t.Fail()
+		}
+	}
+}
+
+func Test_UpdateJSON_MissingETag(t *testing.T) {
+	t.Log("Given server that sends no ETag; update should fail with ErrMissingETag")
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{}`))
+	}))
+	defer server.Close()
+
+	var todo versionedTodo
+	err := UpdateJSON(context.Background(), New(server.URL), "/todos/1", &todo, func(*versionedTodo) error {
+		return nil
+	})
+	if !errors.Is(err, ErrMissingETag) {
+		t.Fail()
+	}
+}
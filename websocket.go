@@ -0,0 +1,463 @@
+package gopunch
+
+import (
+	"bufio"
+	"context"
+	"crypto/rand"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+	"unicode/utf8"
+)
+
+// WebSocket message types, as defined by RFC 6455 opcodes
+const (
+	TextMessage   = 1
+	BinaryMessage = 2
+	CloseMessage  = 8
+	PingMessage   = 9
+	PongMessage   = 10
+)
+
+// WebSocket close codes used by the client
+const (
+	CloseNormalClosure   = 1000
+	CloseProtocolError   = 1002
+	CloseInvalidPayload  = 1007
+	CloseMessageTooBig   = 1009
+	closeNoStatusPresent = 1005
+)
+
+const websocketGUID = "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"
+
+// DefaultWebSocketReadLimit
+//
+//	max size of a single message, can be changed with SetReadLimit
+const DefaultWebSocketReadLimit = 32 << 20
+
+var ErrBadHandshake = errors.New("websocket: bad handshake")
+var ErrReadLimit = errors.New("websocket: message exceeds read limit")
+var ErrProtocol = errors.New("websocket: protocol error")
+
+// CloseError
+//
+//	returned by reads once the server closed the connection
+type CloseError struct {
+	Code int
+	Text string
+}
+
+func (e *CloseError) Error() string {
+	return fmt.Sprintf("websocket: closed with code %d %s", e.Code, e.Text)
+}
+
+// WebSocketConn
+//
+//	client side of a websocket connection
+//	one goroutine may read while others write
+type WebSocketConn struct {
+	rwc         io.ReadWriteCloser
+	reader      *bufio.Reader
+	writeMu     sync.Mutex
+	readLimit   int64
+	subprotocol string
+	lastPong    atomic.Int64
+	closeOnce   sync.Once
+	done        chan struct{}
+}
+
+// WebSocket
+//
+//	takes context, endpoint and option functions
+//	performs the RFC 6455 handshake through the client so base url, transport, TLS config and cookie jar are reused
+//	the base url may use http(s) or ws(s) schemes
+//	returns *WebSocketConn
+func (c *Client) WebSocket(ctx context.Context, endPoint string, opts ...Option) (*WebSocketConn, error) {
+	completeUrl := c.pathFixJoin(c.baseUrl, endPoint)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, completeUrl, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	switch req.URL.Scheme {
+	case "ws":
+		req.URL.Scheme = "http"
+	case "wss":
+		req.URL.Scheme = "https"
+	}
+
+	for _, opt := range opts {
+		opt(req)
+	}
+
+	var nonce [16]byte
+	if _, err := rand.Read(nonce[:]); err != nil {
+		return nil, err
+	}
+
+	key := base64.StdEncoding.EncodeToString(nonce[:])
+	req.Header.Set("Upgrade", "websocket")
+	req.Header.Set("Connection", "Upgrade")
+	req.Header.Set("Sec-WebSocket-Key", key)
+	req.Header.Set("Sec-WebSocket-Version", "13")
+
+	resp := c.do(req)
+	if resp.Err() != nil {
+		return nil, resp.Err()
+	}
+
+	httpResponse := resp.HttpResponse()
+	if httpResponse.StatusCode != http.StatusSwitchingProtocols {
+		resp.Close()
+		return nil, fmt.Errorf("%w: unexpected status %s", ErrBadHandshake, httpResponse.Status)
+	}
+
+	if !strings.EqualFold(httpResponse.Header.Get("Upgrade"), "websocket") ||
+		!headerContainsToken(httpResponse.Header, "Connection", "upgrade") ||
+		httpResponse.Header.Get("Sec-WebSocket-Accept") != websocketAccept(key) {
+		resp.Close()
+		return nil, fmt.Errorf("%w: invalid upgrade headers", ErrBadHandshake)
+	}
+
+	rwc, ok := httpResponse.Body.(io.ReadWriteCloser)
+	if !ok {
+		resp.Close()
+		return nil, fmt.Errorf("%w: connection is not writable", ErrBadHandshake)
+	}
+
+	conn := &WebSocketConn{
+		rwc:         rwc,
+		reader:      bufio.NewReader(rwc),
+		readLimit:   DefaultWebSocketReadLimit,
+		subprotocol: httpResponse.Header.Get("Sec-WebSocket-Protocol"),
+		done:        make(chan struct{}),
+	}
+	conn.lastPong.Store(time.Now().UnixNano())
+
+	return conn, nil
+}
+
+func websocketAccept(key string) string {
+	sum := sha1.Sum([]byte(key + websocketGUID))
+	return base64.StdEncoding.EncodeToString(sum[:])
+}
+
+func headerContainsToken(header http.Header, name, token string) bool {
+	for _, value := range header.Values(name) {
+		for _, part := range strings.Split(value, ",") {
+			if strings.EqualFold(strings.TrimSpace(part), token) {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// Subprotocol
+//
+//	returns the subprotocol selected by the server
+func (c *WebSocketConn) Subprotocol() string {
+	return c.subprotocol
+}
+
+// SetReadLimit
+//
+//	sets the max size of a single message
+func (c *WebSocketConn) SetReadLimit(limit int64) {
+	c.readLimit = limit
+}
+
+// ReadMessage
+//
+//	blocks until a complete text or binary message arrives
+//	pings are answered automatically while reading
+//	returns message type, payload and error, *CloseError once the server closed the connection
+func (c *WebSocketConn) ReadMessage() (int, []byte, error) {
+	messageType := 0
+	var message []byte
+
+	for {
+		fin, opcode, masked, payload, err := readFrame(c.reader, c.readLimit)
+		if errors.Is(err, ErrReadLimit) {
+			c.closeWithCode(CloseMessageTooBig)
+			return 0, nil, err
+		}
+
+		if err != nil {
+			return 0, nil, err
+		}
+
+		// servers must never mask their frames
+		if masked || (opcode >= CloseMessage && (!fin || len(payload) > 125)) {
+			c.closeWithCode(CloseProtocolError)
+			return 0, nil, ErrProtocol
+		}
+
+		switch opcode {
+		case PingMessage:
+			if err := c.writeFrame(PongMessage, payload); err != nil {
+				return 0, nil, err
+			}
+			continue
+		case PongMessage:
+			c.lastPong.Store(time.Now().UnixNano())
+			continue
+		case CloseMessage:
+			closeErr := &CloseError{Code: closeNoStatusPresent}
+			if len(payload) >= 2 {
+				closeErr.Code = int(binary.BigEndian.Uint16(payload))
+				closeErr.Text = string(payload[2:])
+			}
+			c.closeWithCode(CloseNormalClosure)
+			return 0, nil, closeErr
+		case 0:
+			if messageType == 0 {
+				c.closeWithCode(CloseProtocolError)
+				return 0, nil, ErrProtocol
+			}
+		case TextMessage, BinaryMessage:
+			if messageType != 0 {
+				c.closeWithCode(CloseProtocolError)
+				return 0, nil, ErrProtocol
+			}
+			messageType = opcode
+		default:
+			c.closeWithCode(CloseProtocolError)
+			return 0, nil, ErrProtocol
+		}
+
+		message = append(message, payload...)
+		if c.readLimit > 0 && int64(len(message)) > c.readLimit {
+			c.closeWithCode(CloseMessageTooBig)
+			return 0, nil, ErrReadLimit
+		}
+
+		if !fin {
+			continue
+		}
+
+		if messageType == TextMessage && !utf8.Valid(message) {
+			c.closeWithCode(CloseInvalidPayload)
+			return 0, nil, ErrProtocol
+		}
+
+		return messageType, message, nil
+	}
+}
+
+// WriteMessage
+//
+//	takes TextMessage or BinaryMessage and the payload, sends it as a single frame
+func (c *WebSocketConn) WriteMessage(messageType int, data []byte) error {
+	if messageType != TextMessage && messageType != BinaryMessage {
+		return fmt.Errorf("websocket: invalid message type %d", messageType)
+	}
+
+	return c.writeFrame(messageType, data)
+}
+
+// ReadJSON
+//
+//	reads the next message and json unmarshals it into dest
+func (c *WebSocketConn) ReadJSON(dest interface{}) error {
+	_, data, err := c.ReadMessage()
+	if err != nil {
+		return err
+	}
+
+	return json.Unmarshal(data, dest)
+}
+
+// WriteJSON
+//
+//	json marshals v and sends it as a text message
+func (c *WebSocketConn) WriteJSON(v interface{}) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+
+	return c.writeFrame(TextMessage, data)
+}
+
+// Ping
+//
+//	sends a ping with the given payload, at most 125 bytes
+func (c *WebSocketConn) Ping(data []byte) error {
+	if len(data) > 125 {
+		return ErrProtocol
+	}
+
+	return c.writeFrame(PingMessage, data)
+}
+
+// KeepAlive
+//
+//	sends a ping every interval and closes the connection when no pong arrived within interval plus timeout
+//	pongs are only seen while some goroutine is reading messages
+//	does nothing when interval is not positive
+func (c *WebSocketConn) KeepAlive(interval, timeout time.Duration) {
+	if interval <= 0 {
+		return
+	}
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-c.done:
+				return
+			case <-ticker.C:
+			}
+
+			lastPong := time.Unix(0, c.lastPong.Load())
+			if time.Since(lastPong) > interval+timeout {
+				c.Close()
+				return
+			}
+
+			if err := c.Ping(nil); err != nil {
+				return
+			}
+		}
+	}()
+}
+
+// Close
+//
+//	sends a normal closure frame and closes the connection
+func (c *WebSocketConn) Close() error {
+	return c.closeWithCode(CloseNormalClosure)
+}
+
+func (c *WebSocketConn) closeWithCode(code int) error {
+	var err error
+	c.closeOnce.Do(func() {
+		payload := make([]byte, 2)
+		binary.BigEndian.PutUint16(payload, uint16(code))
+		c.writeFrame(CloseMessage, payload)
+
+		close(c.done)
+		err = c.rwc.Close()
+	})
+
+	return err
+}
+
+func (c *WebSocketConn) writeFrame(opcode int, payload []byte) error {
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+
+	return writeFrame(c.rwc, opcode, payload, true)
+}
+
+// writeFrame writes a single final frame, clients must mask, servers must not
+func writeFrame(w io.Writer, opcode int, payload []byte, mask bool) error {
+	header := make([]byte, 2, 14)
+	header[0] = 0x80 | byte(opcode)
+
+	length := len(payload)
+	switch {
+	case length <= 125:
+		header[1] = byte(length)
+	case length <= 0xFFFF:
+		header[1] = 126
+		header = binary.BigEndian.AppendUint16(header, uint16(length))
+	default:
+		header[1] = 127
+		header = binary.BigEndian.AppendUint64(header, uint64(length))
+	}
+
+	data := payload
+	if mask {
+		header[1] |= 0x80
+
+		var key [4]byte
+		if _, err := rand.Read(key[:]); err != nil {
+			return err
+		}
+
+		header = append(header, key[:]...)
+		data = make([]byte, length)
+		for i := range payload {
+			data[i] = payload[i] ^ key[i%4]
+		}
+	}
+
+	if _, err := w.Write(append(header, data...)); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// readFrame reads a single frame and unmasks its payload
+func readFrame(r *bufio.Reader, limit int64) (fin bool, opcode int, masked bool, payload []byte, err error) {
+	var header [2]byte
+	if _, err = io.ReadFull(r, header[:]); err != nil {
+		return
+	}
+
+	// no extensions are negotiated so reserved bits must be zero
+	if header[0]&0x70 != 0 {
+		err = ErrProtocol
+		return
+	}
+
+	fin = header[0]&0x80 != 0
+	opcode = int(header[0] & 0x0F)
+	masked = header[1]&0x80 != 0
+
+	length := uint64(header[1] & 0x7F)
+	switch length {
+	case 126:
+		var extended [2]byte
+		if _, err = io.ReadFull(r, extended[:]); err != nil {
+			return
+		}
+		length = uint64(binary.BigEndian.Uint16(extended[:]))
+	case 127:
+		var extended [8]byte
+		if _, err = io.ReadFull(r, extended[:]); err != nil {
+			return
+		}
+		length = binary.BigEndian.Uint64(extended[:])
+	}
+
+	if limit > 0 && length > uint64(limit) {
+		err = ErrReadLimit
+		return
+	}
+
+	var key [4]byte
+	if masked {
+		if _, err = io.ReadFull(r, key[:]); err != nil {
+			return
+		}
+	}
+
+	payload = make([]byte, length)
+	if _, err = io.ReadFull(r, payload); err != nil {
+		return
+	}
+
+	if masked {
+		for i := range payload {
+			payload[i] ^= key[i%4]
+		}
+	}
+
+	return
+}
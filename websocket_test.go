@@ -0,0 +1,197 @@
+package gopunch
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"errors"
+	"net/http"
+	"net/http/cookiejar"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+// websocketEchoServer accepts the handshake, pings the client once and echoes every message back
+func websocketEchoServer(t *testing.T, seen func(r *http.Request)) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if seen != nil {
+			seen(r)
+		}
+
+		if r.Header.Get("Sec-WebSocket-Version") != "13" || !strings.EqualFold(r.Header.Get("Upgrade"), "websocket") {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		conn, rw, err := w.(http.Hijacker).Hijack()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+
+		rw.WriteString("HTTP/1.1 101 Switching Protocols\r\nUpgrade: websocket\r\nConnection: Upgrade\r\n")
+		rw.WriteString("Sec-WebSocket-Accept: " + websocketAccept(r.Header.Get("Sec-WebSocket-Key")) + "\r\n")
+		if protocol := r.Header.Get("Sec-WebSocket-Protocol"); protocol != "" {
+			rw.WriteString("Sec-WebSocket-Protocol: " + strings.Split(protocol, ",")[0] + "\r\n")
+		}
+		rw.WriteString("\r\n")
+		rw.Flush()
+
+		writeFrame(conn, PingMessage, []byte("srv"), false)
+
+		reader := bufio.NewReader(rw)
+		for {
+			_, opcode, masked, payload, err := readFrame(reader, 0)
+			if err != nil || !masked {
+				return
+			}
+
+			switch opcode {
+			case CloseMessage:
+				writeFrame(conn, CloseMessage, payload, false)
+				return
+			case PingMessage:
+				writeFrame(conn, PongMessage, payload, false)
+			case PongMessage:
+				writeFrame(conn, TextMessage, []byte("pong:"+string(payload)), false)
+			default:
+				writeFrame(conn, opcode, payload, false)
+			}
+		}
+	}))
+}
+
+func Test_WebSocket(t *testing.T) {
+	t.Log("Given echo server; handshake should reuse base url, options and cookies and messages should round trip")
+	var handshake *http.Request
+	server := websocketEchoServer(t, func(r *http.Request) { handshake = r })
+	defer server.Close()
+
+	client := New(strings.Replace(server.URL, "http://", "ws://", 1) + "/api/")
+	jar, _ := cookiejar.New(nil)
+	serverURL, _ := url.Parse(server.URL)
+	jar.SetCookies(serverURL, []*http.Cookie{{Name: "session", Value: "abc"}})
+	client.HttpClient().Jar = jar
+
+	conn, err := client.WebSocket(context.Background(), "/ws", WithHeaders(map[string]string{
+		"Sec-WebSocket-Protocol": "chat",
+		"X-Token":                "secret",
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	if handshake.URL.Path != "/api/ws" || handshake.Header.Get("X-Token") != "secret" {
+		t.Fail()
+	}
+
+	if cookie, err := handshake.Cookie("session"); err != nil || cookie.Value != "abc" {
+		t.Log("cookie from jar was not sent")
+		t.Fail()
+	}
+
+	if conn.Subprotocol() != "chat" {
+		t.Fail()
+	}
+
+	// the server's ping is answered while reading, the server echoes our pong back as text
+	if err := conn.WriteMessage(TextMessage, []byte("hello")); err != nil {
+		t.Fatal(err)
+	}
+
+	messageType, data, err := conn.ReadMessage()
+	if err != nil || messageType != TextMessage || string(data) != "hello" {
+		t.Fail()
+	}
+
+	_, data, err = conn.ReadMessage()
+	if err != nil || string(data) != "pong:srv" {
+		t.Logf("expected pong echo, got %q", data)
+		t.Fail()
+	}
+
+	big := strings.Repeat("x", 70000)
+	if err := conn.WriteMessage(BinaryMessage, []byte(big)); err != nil {
+		t.Fatal(err)
+	}
+
+	messageType, data, err = conn.ReadMessage()
+	if err != nil || messageType != BinaryMessage || string(data) != big {
+		t.Fail()
+	}
+
+	payload := map[string]interface{}{"op": "sum", "args": []interface{}{1.0, 2.0}}
+	if err := conn.WriteJSON(payload); err != nil {
+		t.Fatal(err)
+	}
+
+	var echoed map[string]interface{}
+	if err := conn.ReadJSON(&echoed); err != nil || echoed["op"] != "sum" {
+		t.Fail()
+	}
+}
+
+func Test_WebSocket_Close(t *testing.T) {
+	t.Log("Given closed connection; server close reply should surface as CloseError")
+	server := websocketEchoServer(t, nil)
+	defer server.Close()
+
+	conn, err := New(server.URL).WebSocket(context.Background(), "/ws")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	payload := make([]byte, 2)
+	binary.BigEndian.PutUint16(payload, 4000)
+	conn.writeFrame(CloseMessage, payload)
+
+	var closeErr *CloseError
+	if _, _, err := conn.ReadMessage(); !errors.As(err, &closeErr) || closeErr.Code != 4000 {
+		t.Log(err)
+		t.Fail()
+	}
+}
+
+func Test_WebSocket_KeepAlive(t *testing.T) {
+	t.Log("Given keepalive; pings should be sent periodically and pongs should keep the connection open")
+	server := websocketEchoServer(t, nil)
+	defer server.Close()
+
+	conn, err := New(server.URL).WebSocket(context.Background(), "/ws")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	conn.KeepAlive(10*time.Millisecond, time.Second)
+
+	// the echo server answers our pings with pongs, which are consumed silently
+	go conn.ReadMessage()
+	time.Sleep(50 * time.Millisecond)
+
+	if err := conn.WriteMessage(TextMessage, []byte("still open")); err != nil {
+		t.Fail()
+	}
+
+	t.Log("Given non-positive interval; keepalive should do nothing")
+	conn.KeepAlive(0, time.Second)
+	if err := conn.WriteMessage(TextMessage, []byte("still open")); err != nil {
+		t.Fail()
+	}
+}
+
+func Test_WebSocket_BadHandshake(t *testing.T) {
+	t.Log("Given endpoint that does not upgrade; WebSocket should return ErrBadHandshake")
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	if _, err := New(server.URL).WebSocket(context.Background(), "/ws"); !errors.Is(err, ErrBadHandshake) {
+		t.Fail()
+	}
+}